@@ -222,6 +222,47 @@ func (c *bcacheCollector) updateBcacheStats(ch chan<- prometheus.Metric, s *bcac
 				extraLabel:      []string{"backing_device"},
 				extraLabelValue: bdev.Name,
 			},
+			{
+				name:            "writeback_rate_bytes_per_second",
+				desc:            "Current writeback rate of this backing device.",
+				value:           float64(bdev.WritebackRate),
+				metricType:      prometheus.GaugeValue,
+				extraLabel:      []string{"backing_device"},
+				extraLabelValue: bdev.Name,
+			},
+			// metrics in /sys/fs/bcache/<uuid>/<bdev>/writeback_rate_debug
+			{
+				name:            "writeback_rate_debug_dirty_bytes",
+				desc:            "Amount of dirty data seen by the writeback rate controller.",
+				value:           float64(bdev.WritebackRateDebug.Dirty),
+				metricType:      prometheus.GaugeValue,
+				extraLabel:      []string{"backing_device"},
+				extraLabelValue: bdev.Name,
+			},
+			{
+				name:            "writeback_rate_debug_target_bytes",
+				desc:            "Amount of dirty data the writeback rate controller aims for.",
+				value:           float64(bdev.WritebackRateDebug.Target),
+				metricType:      prometheus.GaugeValue,
+				extraLabel:      []string{"backing_device"},
+				extraLabelValue: bdev.Name,
+			},
+			{
+				name:            "writeback_rate_debug_proportional_bytes_per_second",
+				desc:            "Proportional term of the writeback rate controller.",
+				value:           float64(bdev.WritebackRateDebug.Proportional),
+				metricType:      prometheus.GaugeValue,
+				extraLabel:      []string{"backing_device"},
+				extraLabelValue: bdev.Name,
+			},
+			{
+				name:            "writeback_rate_debug_integral_bytes_per_second",
+				desc:            "Integral term of the writeback rate controller.",
+				value:           float64(bdev.WritebackRateDebug.Integral),
+				metricType:      prometheus.GaugeValue,
+				extraLabel:      []string{"backing_device"},
+				extraLabelValue: bdev.Name,
+			},
 		}
 		allMetrics = append(allMetrics, metrics...)
 
@@ -267,6 +308,22 @@ func (c *bcacheCollector) updateBcacheStats(ch chan<- prometheus.Metric, s *bcac
 				extraLabel:      []string{"cache_device"},
 				extraLabelValue: cache.Name,
 			},
+			{
+				name:            "priority_stats_clean_percent",
+				desc:            "The percentage of the cache that contains clean data.",
+				value:           float64(cache.Priority.CleanPercent),
+				metricType:      prometheus.GaugeValue,
+				extraLabel:      []string{"cache_device"},
+				extraLabelValue: cache.Name,
+			},
+			{
+				name:            "priority_stats_dirty_percent",
+				desc:            "The percentage of the cache that contains dirty data.",
+				value:           float64(cache.Priority.DirtyPercent),
+				metricType:      prometheus.GaugeValue,
+				extraLabel:      []string{"cache_device"},
+				extraLabelValue: cache.Name,
+			},
 			{
 				name:            "priority_stats_metadata_percent",
 				desc:            "Bcache's metadata overhead.",
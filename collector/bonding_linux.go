@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -27,7 +28,35 @@ import (
 )
 
 type bondingCollector struct {
-	slaves, active typedDesc
+	slaves, active, info, adInfo                                    typedDesc
+	slaveUp, slaveMIIUp, slaveLinkFailures, slaveSpeed, slaveActive typedDesc
+	slaveInfo, slaveADAggregator                                    typedDesc
+}
+
+// bondingMaster holds the configuration and state of a bonding interface.
+type bondingMaster struct {
+	slaves, active int
+
+	// Settings are read from /sys/class/net/<master>/bonding and are empty
+	// if the kernel doesn't expose them.
+	mode, lacpRate, xmitHashPolicy, primary string
+	// The active aggregator and its LACP partner, only set in 802.3ad mode.
+	adAggregatorID, adPartnerMAC string
+
+	slaveStats []bondingSlave
+}
+
+// bondingSlave holds the state of a slave of a bonding interface.
+type bondingSlave struct {
+	name         string
+	up           bool
+	miiUp        bool
+	active       bool
+	linkFailures uint64
+	// Link speed in Mbps, -1 if unknown.
+	speed          int64
+	duplex         string
+	adAggregatorID string
 }
 
 func init() {
@@ -48,6 +77,51 @@ func NewBondingCollector() (Collector, error) {
 			"Number of active slaves per bonding interface.",
 			[]string{"master"}, nil,
 		), prometheus.GaugeValue},
+		info: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bonding", "info"),
+			"Configuration of the bonding interface.",
+			[]string{"master", "mode", "lacp_rate", "xmit_hash_policy", "primary"}, nil,
+		), prometheus.GaugeValue},
+		adInfo: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bonding", "ad_info"),
+			"Active 802.3ad aggregator of the bonding interface and the MAC address of its LACP partner.",
+			[]string{"master", "aggregator_id", "partner_mac"}, nil,
+		), prometheus.GaugeValue},
+		slaveUp: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bonding", "slave_up"),
+			"Whether the operational state of the slave is up.",
+			[]string{"master", "slave"}, nil,
+		), prometheus.GaugeValue},
+		slaveMIIUp: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bonding", "slave_mii_up"),
+			"Whether the MII status of the slave is up.",
+			[]string{"master", "slave"}, nil,
+		), prometheus.GaugeValue},
+		slaveLinkFailures: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bonding", "slave_link_failures_total"),
+			"Number of link failures of the slave.",
+			[]string{"master", "slave"}, nil,
+		), prometheus.CounterValue},
+		slaveSpeed: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bonding", "slave_speed_bytes"),
+			"Link speed of the slave in bytes per second.",
+			[]string{"master", "slave"}, nil,
+		), prometheus.GaugeValue},
+		slaveActive: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bonding", "slave_active"),
+			"Whether the slave is active rather than backup.",
+			[]string{"master", "slave"}, nil,
+		), prometheus.GaugeValue},
+		slaveInfo: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bonding", "slave_info"),
+			"Duplex mode of the slave.",
+			[]string{"master", "slave", "duplex"}, nil,
+		), prometheus.GaugeValue},
+		slaveADAggregator: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "bonding", "slave_ad_aggregator_id"),
+			"802.3ad aggregator the slave belongs to.",
+			[]string{"master", "slave"}, nil,
+		), prometheus.GaugeValue},
 	}, nil
 }
 
@@ -63,14 +137,41 @@ func (c *bondingCollector) Update(ch chan<- prometheus.Metric) error {
 		return err
 	}
 	for master, status := range bondingStats {
-		ch <- c.slaves.mustNewConstMetric(float64(status[0]), master)
-		ch <- c.active.mustNewConstMetric(float64(status[1]), master)
+		ch <- c.slaves.mustNewConstMetric(float64(status.slaves), master)
+		ch <- c.active.mustNewConstMetric(float64(status.active), master)
+		if status.mode != "" {
+			ch <- c.info.mustNewConstMetric(1, master, status.mode, status.lacpRate, status.xmitHashPolicy, status.primary)
+		}
+		if status.adAggregatorID != "" {
+			ch <- c.adInfo.mustNewConstMetric(1, master, status.adAggregatorID, status.adPartnerMAC)
+		}
+
+		for _, slave := range status.slaveStats {
+			ch <- c.slaveUp.mustNewConstMetric(bondingFlag(slave.up), master, slave.name)
+			ch <- c.slaveMIIUp.mustNewConstMetric(bondingFlag(slave.miiUp), master, slave.name)
+			ch <- c.slaveLinkFailures.mustNewConstMetric(float64(slave.linkFailures), master, slave.name)
+			ch <- c.slaveActive.mustNewConstMetric(bondingFlag(slave.active), master, slave.name)
+			if slave.speed >= 0 {
+				ch <- c.slaveSpeed.mustNewConstMetric(float64(slave.speed)*1000*1000/8, master, slave.name)
+			}
+			if slave.duplex != "" {
+				ch <- c.slaveInfo.mustNewConstMetric(1, master, slave.name, slave.duplex)
+			}
+			if slave.adAggregatorID != "" {
+				id, err := strconv.ParseFloat(slave.adAggregatorID, 64)
+				if err != nil {
+					log.Debugf("Not collecting aggregator ID %q of bonding slave %s of %s: %s", slave.adAggregatorID, slave.name, master, err)
+					continue
+				}
+				ch <- c.slaveADAggregator.mustNewConstMetric(id, master, slave.name)
+			}
+		}
 	}
 	return nil
 }
 
-func readBondingStats(root string) (status map[string][2]int, err error) {
-	status = map[string][2]int{}
+func readBondingStats(root string) (status map[string]*bondingMaster, err error) {
+	status = map[string]*bondingMaster{}
 	masters, err := ioutil.ReadFile(path.Join(root, "bonding_masters"))
 	if err != nil {
 		return nil, err
@@ -80,22 +181,83 @@ func readBondingStats(root string) (status map[string][2]int, err error) {
 		if err != nil {
 			return nil, err
 		}
-		sstat := [2]int{0, 0}
+		mstat := &bondingMaster{
+			mode:           readBondingSetting(path.Join(root, master, "bonding", "mode")),
+			lacpRate:       readBondingSetting(path.Join(root, master, "bonding", "lacp_rate")),
+			xmitHashPolicy: readBondingSetting(path.Join(root, master, "bonding", "xmit_hash_policy")),
+			primary:        readBondingSetting(path.Join(root, master, "bonding", "primary")),
+			adAggregatorID: readBondingSetting(path.Join(root, master, "bonding", "ad_aggregator")),
+			adPartnerMAC:   readBondingSetting(path.Join(root, master, "bonding", "ad_partner_mac")),
+		}
 		for _, slave := range strings.Fields(string(slaves)) {
-			state, err := ioutil.ReadFile(path.Join(root, master, fmt.Sprintf("lower_%s", slave), "operstate"))
+			mstat.slaves++
+
+			slaveDir := path.Join(root, master, fmt.Sprintf("lower_%s", slave))
+			state, err := ioutil.ReadFile(path.Join(slaveDir, "operstate"))
 			if os.IsNotExist(err) {
 				// some older? kernels use slave_ prefix
-				state, err = ioutil.ReadFile(path.Join(root, master, fmt.Sprintf("slave_%s", slave), "operstate"))
+				slaveDir = path.Join(root, master, fmt.Sprintf("slave_%s", slave))
+				state, err = ioutil.ReadFile(path.Join(slaveDir, "operstate"))
+			}
+			if os.IsNotExist(err) {
+				// The slave may be in the middle of being removed.
+				log.Debugf("Not collecting bonding slave %s of %s: %s", slave, master, err)
+				continue
 			}
 			if err != nil {
 				return nil, err
 			}
-			sstat[0]++
-			if strings.TrimSpace(string(state)) == "up" {
-				sstat[1]++
+
+			sstat := readBondingSlave(slaveDir)
+			sstat.name = slave
+			sstat.up = strings.TrimSpace(string(state)) == "up"
+			if sstat.up {
+				mstat.active++
 			}
+			mstat.slaveStats = append(mstat.slaveStats, sstat)
 		}
-		status[master] = sstat
+		status[master] = mstat
 	}
 	return status, err
 }
+
+// readBondingSlave reads the state of the slave device in dir. Files which
+// can't be read are skipped, reading speed fails for links which are down.
+func readBondingSlave(dir string) bondingSlave {
+	sstat := bondingSlave{
+		miiUp:          readBondingSetting(path.Join(dir, "bonding_slave", "mii_status")) == "up",
+		active:         readBondingSetting(path.Join(dir, "bonding_slave", "state")) == "active",
+		speed:          -1,
+		duplex:         readBondingSetting(path.Join(dir, "duplex")),
+		adAggregatorID: readBondingSetting(path.Join(dir, "bonding_slave", "ad_aggregator_id")),
+	}
+	if v, err := strconv.ParseUint(readBondingSetting(path.Join(dir, "bonding_slave", "link_failure_count")), 10, 64); err == nil {
+		sstat.linkFailures = v
+	}
+	if v, err := strconv.ParseInt(readBondingSetting(path.Join(dir, "speed")), 10, 64); err == nil && v >= 0 {
+		sstat.speed = v
+	}
+	return sstat
+}
+
+// readBondingSetting returns the first field of a bonding sysfs file, which
+// holds the name of the setting followed by its numeric value, e.g.
+// "802.3ad 4". Files which don't exist or are empty yield "".
+func readBondingSetting(file string) string {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+func bondingFlag(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -14,6 +14,9 @@
 package collector
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -22,15 +25,86 @@ func TestBonding(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if bondingStats["bond0"][0] != 0 || bondingStats["bond0"][1] != 0 {
+	if bondingStats["bond0"].slaves != 0 || bondingStats["bond0"].active != 0 {
 		t.Fatal("bond0 in unexpected state")
 	}
 
-	if bondingStats["int"][0] != 2 || bondingStats["int"][1] != 1 {
+	if bondingStats["int"].slaves != 2 || bondingStats["int"].active != 1 {
 		t.Fatal("int in unexpected state")
 	}
 
-	if bondingStats["dmz"][0] != 2 || bondingStats["dmz"][1] != 2 {
+	if bondingStats["dmz"].slaves != 2 || bondingStats["dmz"].active != 2 {
 		t.Fatal("dmz in unexpected state")
 	}
 }
+
+func TestBondingSettings(t *testing.T) {
+	bondingStats, err := readBondingStats("fixtures/sys/class/net")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bondingStats["bond0"].mode != "" {
+		t.Errorf("bond0 should have no settings, got mode %q", bondingStats["bond0"].mode)
+	}
+
+	dmz := bondingStats["dmz"]
+	if dmz.mode != "802.3ad" || dmz.lacpRate != "fast" || dmz.xmitHashPolicy != "layer3+4" || dmz.primary != "" {
+		t.Errorf("dmz has unexpected settings: %+v", dmz)
+	}
+	if dmz.adAggregatorID != "1" || dmz.adPartnerMAC != "00:1c:73:a1:b2:c3" {
+		t.Errorf("dmz has unexpected 802.3ad state: %+v", dmz)
+	}
+
+	want := []bondingSlave{
+		{name: "eth5", up: true, miiUp: true, active: true, linkFailures: 1, speed: 1000, duplex: "full"},
+		{name: "eth1", linkFailures: 4, speed: -1, duplex: "unknown"},
+	}
+	if len(bondingStats["int"].slaveStats) != len(want) {
+		t.Fatalf("want %d slaves of int, got %d", len(want), len(bondingStats["int"].slaveStats))
+	}
+	for i, slave := range bondingStats["int"].slaveStats {
+		if slave != want[i] {
+			t.Errorf("want %+v, got %+v", want[i], slave)
+		}
+	}
+	if dmz.slaveStats[1].adAggregatorID != "2" {
+		t.Errorf("want eth4 of dmz in aggregator 2, got %q", dmz.slaveStats[1].adAggregatorID)
+	}
+}
+
+func TestBondingMissingSlave(t *testing.T) {
+	root, err := ioutil.TempDir("", "bonding")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for file, content := range map[string]string{
+		"bonding_masters":                "bond1\n",
+		"bond1/bonding/slaves":           "eth0 eth1\n",
+		"bond1/lower_eth0/operstate":     "up\n",
+		"bond1/lower_eth0/speed":         "25000\n",
+		"bond1/lower_eth0/duplex":        "full\n",
+		"bond1/bonding/xmit_hash_policy": "layer2 0\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bondingStats, err := readBondingStats(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bond1 := bondingStats["bond1"]
+	if bond1.slaves != 2 || bond1.active != 1 || len(bond1.slaveStats) != 1 {
+		t.Fatalf("bond1 in unexpected state: %+v", bond1)
+	}
+	if bond1.slaveStats[0].speed != 25000 {
+		t.Errorf("want speed 25000 of eth0, got %d", bond1.slaveStats[0].speed)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nobtrfs
+
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Request codes and argument structs from linux/btrfs.h.
+const (
+	// _IOR(BTRFS_IOCTL_MAGIC, 31, struct btrfs_ioctl_fs_info_args)
+	btrfsIocFsInfo = 0x8400941f
+	// _IOWR(BTRFS_IOCTL_MAGIC, 30, struct btrfs_ioctl_dev_info_args)
+	btrfsIocDevInfo = 0xd000941e
+	// _IOWR(BTRFS_IOCTL_MAGIC, 52, struct btrfs_ioctl_get_dev_stats)
+	btrfsIocGetDevStats = 0xc4089434
+
+	btrfsDevStatValuesMax = 5
+)
+
+// btrfsDeviceErrorTypes are the names of the counters returned by
+// BTRFS_IOC_GET_DEV_STATS, in order.
+var btrfsDeviceErrorTypes = []string{"write", "read", "flush", "corruption", "generation"}
+
+type btrfsIoctlFsInfoArgs struct {
+	maxID          uint64
+	numDevices     uint64
+	fsid           [16]byte
+	nodesize       uint32
+	sectorsize     uint32
+	cloneAlignment uint32
+	_              uint32
+	_              [122]uint64
+}
+
+type btrfsIoctlDevInfoArgs struct {
+	devid      uint64
+	uuid       [16]byte
+	bytesUsed  uint64
+	totalBytes uint64
+	_          [379]uint64
+	path       [1024]byte
+}
+
+type btrfsIoctlGetDevStats struct {
+	devid   uint64
+	nrItems uint64
+	flags   uint64
+	values  [btrfsDevStatValuesMax]uint64
+	_       [128 - 2 - btrfsDevStatValuesMax]uint64
+}
+
+// btrfsDeviceErrors holds the error counters of a device, ordered as
+// btrfsDeviceErrorTypes.
+type btrfsDeviceErrors struct {
+	name   string
+	values [btrfsDevStatValuesMax]uint64
+}
+
+// btrfsDeviceStats returns the filesystem UUID and the error counters of all
+// devices of the btrfs filesystem mounted at mountPoint.
+func btrfsDeviceStats(mountPoint string) (string, []btrfsDeviceErrors, error) {
+	f, err := os.Open(mountPoint)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	var fsInfo btrfsIoctlFsInfoArgs
+	if err := btrfsIoctl(f, btrfsIocFsInfo, unsafe.Pointer(&fsInfo)); err != nil {
+		return "", nil, fmt.Errorf("BTRFS_IOC_FS_INFO failed: %s", err)
+	}
+	b := fsInfo.fsid
+	uuid := fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+
+	var devices []btrfsDeviceErrors
+	// Device IDs of removed devices are not reused, so there may be gaps.
+	for devid := uint64(1); devid <= fsInfo.maxID; devid++ {
+		devInfo := btrfsIoctlDevInfoArgs{devid: devid}
+		err := btrfsIoctl(f, btrfsIocDevInfo, unsafe.Pointer(&devInfo))
+		if err == unix.ENODEV {
+			continue
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("BTRFS_IOC_DEV_INFO failed for device %d: %s", devid, err)
+		}
+
+		stats := btrfsIoctlGetDevStats{devid: devid, nrItems: btrfsDevStatValuesMax}
+		if err := btrfsIoctl(f, btrfsIocGetDevStats, unsafe.Pointer(&stats)); err != nil {
+			return "", nil, fmt.Errorf("BTRFS_IOC_GET_DEV_STATS failed for device %d: %s", devid, err)
+		}
+
+		path := devInfo.path[:]
+		if i := bytes.IndexByte(path, 0); i >= 0 {
+			path = path[:i]
+		}
+		d := btrfsDeviceErrors{name: filepath.Base(string(path))}
+		// Older kernels may return fewer counters than requested.
+		for i := uint64(0); i < stats.nrItems && i < btrfsDevStatValuesMax; i++ {
+			d.values[i] = stats.values[i]
+		}
+		devices = append(devices, d)
+	}
+	return uuid, devices, nil
+}
+
+func btrfsIoctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nobtrfs
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+// btrfsBlockGroupTypes are the block group types found in
+// /sys/fs/btrfs/<uuid>/allocation.
+var btrfsBlockGroupTypes = []string{"data", "metadata", "system"}
+
+// btrfsStats holds the statistics of a btrfs filesystem found in sysfs.
+type btrfsStats struct {
+	uuid          string
+	label         string
+	globalRsvSize uint64
+	allocation    map[string]*btrfsAllocationStats
+	// Size in bytes of each device of the filesystem.
+	devices map[string]uint64
+}
+
+// btrfsAllocationStats holds the space allocated to a block group type. The
+// disk values include the copies kept by the RAID profiles.
+type btrfsAllocationStats struct {
+	totalBytes     uint64
+	usedBytes      uint64
+	diskTotalBytes uint64
+	diskUsedBytes  uint64
+	profiles       map[string]*btrfsProfileStats
+}
+
+type btrfsProfileStats struct {
+	totalBytes uint64
+	usedBytes  uint64
+}
+
+type btrfsCollector struct {
+	info, globalRsvSize                                  typedDesc
+	allocTotal, allocUsed, allocDiskTotal, allocDiskUsed typedDesc
+	profileTotal, profileUsed, deviceSize, deviceErrors  typedDesc
+}
+
+func init() {
+	registerCollector("btrfs", defaultDisabled, NewBtrfsCollector)
+}
+
+// NewBtrfsCollector returns a new Collector exposing btrfs allocation and
+// device error statistics.
+func NewBtrfsCollector() (Collector, error) {
+	subsystem := "btrfs"
+	return &btrfsCollector{
+		info: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "info"),
+			"Filesystem information.",
+			[]string{"uuid", "label"}, nil,
+		), prometheus.GaugeValue},
+		globalRsvSize: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "global_rsv_size_bytes"),
+			"Size of the global reserve.",
+			[]string{"uuid"}, nil,
+		), prometheus.GaugeValue},
+		allocTotal: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "allocation_size_bytes"),
+			"Amount of space allocated to the block group type.",
+			[]string{"uuid", "block_group_type"}, nil,
+		), prometheus.GaugeValue},
+		allocUsed: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "allocation_used_bytes"),
+			"Amount of space used in the block group type.",
+			[]string{"uuid", "block_group_type"}, nil,
+		), prometheus.GaugeValue},
+		allocDiskTotal: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "allocation_disk_size_bytes"),
+			"Amount of raw disk space allocated to the block group type, including all copies.",
+			[]string{"uuid", "block_group_type"}, nil,
+		), prometheus.GaugeValue},
+		allocDiskUsed: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "allocation_disk_used_bytes"),
+			"Amount of raw disk space used in the block group type, including all copies.",
+			[]string{"uuid", "block_group_type"}, nil,
+		), prometheus.GaugeValue},
+		profileTotal: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "size_bytes"),
+			"Amount of space allocated to the block group type using the RAID profile.",
+			[]string{"uuid", "block_group_type", "mode"}, nil,
+		), prometheus.GaugeValue},
+		profileUsed: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "used_bytes"),
+			"Amount of space used in the block group type using the RAID profile.",
+			[]string{"uuid", "block_group_type", "mode"}, nil,
+		), prometheus.GaugeValue},
+		deviceSize: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "device_size_bytes"),
+			"Size of the device.",
+			[]string{"uuid", "device"}, nil,
+		), prometheus.GaugeValue},
+		deviceErrors: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "device_errors_total"),
+			"Errors reported for the device by the device stats ioctl.",
+			[]string{"uuid", "device", "type"}, nil,
+		), prometheus.CounterValue},
+	}, nil
+}
+
+func (c *btrfsCollector) Update(ch chan<- prometheus.Metric) error {
+	stats, err := readBtrfsStats(sysFilePath("fs/btrfs"))
+	if err != nil {
+		return fmt.Errorf("couldn't get btrfs stats: %s", err)
+	}
+
+	for _, s := range stats {
+		ch <- c.info.mustNewConstMetric(1, s.uuid, s.label)
+		ch <- c.globalRsvSize.mustNewConstMetric(float64(s.globalRsvSize), s.uuid)
+
+		for _, bgType := range btrfsBlockGroupTypes {
+			a, ok := s.allocation[bgType]
+			if !ok {
+				continue
+			}
+			ch <- c.allocTotal.mustNewConstMetric(float64(a.totalBytes), s.uuid, bgType)
+			ch <- c.allocUsed.mustNewConstMetric(float64(a.usedBytes), s.uuid, bgType)
+			ch <- c.allocDiskTotal.mustNewConstMetric(float64(a.diskTotalBytes), s.uuid, bgType)
+			ch <- c.allocDiskUsed.mustNewConstMetric(float64(a.diskUsedBytes), s.uuid, bgType)
+			for mode, p := range a.profiles {
+				ch <- c.profileTotal.mustNewConstMetric(float64(p.totalBytes), s.uuid, bgType, mode)
+				ch <- c.profileUsed.mustNewConstMetric(float64(p.usedBytes), s.uuid, bgType, mode)
+			}
+		}
+
+		for device, size := range s.devices {
+			ch <- c.deviceSize.mustNewConstMetric(float64(size), s.uuid, device)
+		}
+	}
+
+	if len(stats) == 0 {
+		return nil
+	}
+	return c.updateDeviceErrors(ch)
+}
+
+// updateDeviceErrors exposes the device error counters, which are only
+// available through an ioctl on a mounted filesystem.
+func (c *btrfsCollector) updateDeviceErrors(ch chan<- prometheus.Metric) error {
+	mountPoints, err := btrfsMountPoints(procFilePath("1/mounts"))
+	if err != nil {
+		return fmt.Errorf("couldn't get btrfs mount points: %s", err)
+	}
+
+	seen := map[string]bool{}
+	for _, mountPoint := range mountPoints {
+		uuid, devices, err := btrfsDeviceStats(rootfsFilePath(mountPoint))
+		if err != nil {
+			log.Debugf("btrfs collector: couldn't get device stats of %s: %s", mountPoint, err)
+			continue
+		}
+		// Filesystems may be mounted several times.
+		if seen[uuid] {
+			continue
+		}
+		seen[uuid] = true
+
+		for _, d := range devices {
+			for i, errorType := range btrfsDeviceErrorTypes {
+				ch <- c.deviceErrors.mustNewConstMetric(float64(d.values[i]), uuid, d.name, errorType)
+			}
+		}
+	}
+	return nil
+}
+
+// readBtrfsStats reads the statistics of all btrfs filesystems below
+// /sys/fs/btrfs.
+func readBtrfsStats(root string) ([]btrfsStats, error) {
+	// The top level also contains the features directory, only filesystems
+	// have allocation statistics.
+	dirs, err := filepath.Glob(filepath.Join(root, "*", "allocation"))
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []btrfsStats
+	for _, dir := range dirs {
+		s, err := readBtrfsFilesystem(filepath.Dir(dir))
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+func readBtrfsFilesystem(dir string) (btrfsStats, error) {
+	s := btrfsStats{
+		uuid:       filepath.Base(dir),
+		allocation: map[string]*btrfsAllocationStats{},
+		devices:    map[string]uint64{},
+	}
+
+	label, err := ioutil.ReadFile(filepath.Join(dir, "label"))
+	if err != nil && !os.IsNotExist(err) {
+		return s, err
+	}
+	s.label = strings.TrimSpace(string(label))
+
+	if s.globalRsvSize, err = readUintFromFile(filepath.Join(dir, "allocation", "global_rsv_size")); err != nil {
+		return s, err
+	}
+
+	for _, bgType := range btrfsBlockGroupTypes {
+		a, err := readBtrfsAllocation(filepath.Join(dir, "allocation", bgType))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return s, err
+		}
+		s.allocation[bgType] = a
+	}
+
+	devices, err := ioutil.ReadDir(filepath.Join(dir, "devices"))
+	if err != nil && !os.IsNotExist(err) {
+		return s, err
+	}
+	for _, d := range devices {
+		// Size of the block device in 512 byte sectors.
+		sectors, err := readUintFromFile(filepath.Join(dir, "devices", d.Name(), "size"))
+		if err != nil {
+			return s, err
+		}
+		s.devices[d.Name()] = sectors * 512
+	}
+	return s, nil
+}
+
+func readBtrfsAllocation(dir string) (*btrfsAllocationStats, error) {
+	a := &btrfsAllocationStats{profiles: map[string]*btrfsProfileStats{}}
+
+	for file, dst := range map[string]*uint64{
+		"total_bytes": &a.totalBytes,
+		"bytes_used":  &a.usedBytes,
+		"disk_total":  &a.diskTotalBytes,
+		"disk_used":   &a.diskUsedBytes,
+	} {
+		v, err := readUintFromFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		*dst = v
+	}
+
+	// Block groups of each RAID profile in use have their own directory.
+	profiles, err := filepath.Glob(filepath.Join(dir, "*", "used_bytes"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range profiles {
+		profileDir := filepath.Dir(file)
+		p := &btrfsProfileStats{}
+		if p.totalBytes, err = readUintFromFile(filepath.Join(profileDir, "total_bytes")); err != nil {
+			return nil, err
+		}
+		if p.usedBytes, err = readUintFromFile(file); err != nil {
+			return nil, err
+		}
+		a.profiles[filepath.Base(profileDir)] = p
+	}
+	return a, nil
+}
+
+// btrfsMountPoints returns the mount points of btrfs filesystems in the
+// given mounts file.
+func btrfsMountPoints(mountsFile string) ([]string, error) {
+	file, err := os.Open(mountsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var mountPoints []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 3 || parts[2] != "btrfs" {
+			continue
+		}
+		mountPoint := strings.Replace(parts[1], "\\040", " ", -1)
+		mountPoint = strings.Replace(mountPoint, "\\011", "\t", -1)
+		mountPoints = append(mountPoints, mountPoint)
+	}
+	return mountPoints, scanner.Err()
+}
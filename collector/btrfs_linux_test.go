@@ -0,0 +1,65 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nobtrfs
+
+package collector
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestBtrfsStats(t *testing.T) {
+	stats, err := readBtrfsStats("fixtures/sys/fs/btrfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 2, len(stats); want != got {
+		t.Fatalf("want %d filesystems, got %d", want, got)
+	}
+
+	s := stats[0]
+	if s.uuid != "0abb23a9-579b-43e6-ad30-227ef47fcb9d" || s.label != "fixture" {
+		t.Errorf("unexpected filesystem %q with label %q", s.uuid, s.label)
+	}
+	data := s.allocation["data"]
+	if data == nil || data.totalBytes != 2147483648 || data.diskUsedBytes != 1616379904 {
+		t.Fatalf("unexpected data allocation: %+v", data)
+	}
+	if p := data.profiles["raid1"]; p == nil || p.usedBytes != 808189952 {
+		t.Errorf("unexpected raid1 data profile: %+v", p)
+	}
+	if want, got := uint64(10737418240), s.devices["loop26"]; want != got {
+		t.Errorf("want loop26 size %d, got %d", want, got)
+	}
+
+	if _, ok := stats[1].allocation["metadata"].profiles["dup"]; !ok {
+		t.Errorf("want dup metadata profile, got %+v", stats[1].allocation["metadata"].profiles)
+	}
+}
+
+func TestBtrfsIoctlArgSizes(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		got, want uintptr
+	}{
+		{"btrfs_ioctl_fs_info_args", unsafe.Sizeof(btrfsIoctlFsInfoArgs{}), 1024},
+		{"btrfs_ioctl_dev_info_args", unsafe.Sizeof(btrfsIoctlDevInfoArgs{}), 4096},
+		{"btrfs_ioctl_get_dev_stats", unsafe.Sizeof(btrfsIoctlGetDevStats{}), 1032},
+	} {
+		if test.got != test.want {
+			t.Errorf("want struct %s of size %d, got %d", test.name, test.want, test.got)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	// cgroup v1 reports CPU times from cpuacct.stat in USER_HZ.
+	cgroupUserHZ = 100
+	// cgroup v1 reports an unlimited memory limit as the largest page
+	// aligned value, which depends on the page size.
+	cgroupV1MemoryUnlimited = 1 << 62
+)
+
+var (
+	cgroupsMaxDepth = kingpin.Flag("collector.cgroups.max-depth", "Maximum depth of cgroups below the root to report for the cgroups and oom collectors.").Default("2").Int()
+	cgroupsPaths    = kingpin.Flag("collector.cgroups.paths", "Regexp of cgroup paths to report for the cgroups and oom collectors.").Default(".*").String()
+	selfCgroup      = kingpin.Flag("collector.self-cgroup", "Expose the memory and CPU limits and usage of the cgroup node_exporter runs in from the meminfo and cpu collectors, for running it as a sidecar.").Default("false").Bool()
+
+	// Collectors are created for every scrape, warn about falling back to
+	// the root of the hierarchy only once.
+	selfCgroupFallbackOnce sync.Once
+)
+
+// isCgroupV2 returns whether the hierarchy mounted at root uses the unified
+// cgroup v2 layout.
+func isCgroupV2(root string) bool {
+	_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+	return err == nil
+}
+
+// walkCgroupController walks the v1 hierarchy of the given controller, doing
+// nothing if the controller is not mounted.
+func walkCgroupController(root, controller string, maxDepth int, pattern *regexp.Regexp, fn func(cgroup, dir string) error) error {
+	dir := filepath.Join(root, controller)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		log.Debugf("cgroups collector: %s controller is not mounted", controller)
+		return nil
+	}
+	return walkCgroups(dir, maxDepth, pattern, fn)
+}
+
+// walkCgroups calls fn for every cgroup in the hierarchy mounted at root that
+// is at most maxDepth levels below it and whose path matches pattern. The
+// cgroup is passed as its path relative to root, e.g. "/system.slice".
+func walkCgroups(root string, maxDepth int, pattern *regexp.Regexp, fn func(cgroup, dir string) error) error {
+	// Controllers that are co-mounted in v1 are usually reached via a symlink
+	// such as cpu -> cpu,cpuacct.
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Cgroups can be removed while walking the hierarchy.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		cgroup, depth := "/", 0
+		if rel != "." {
+			cgroup = "/" + filepath.ToSlash(rel)
+			depth = strings.Count(cgroup, "/")
+		}
+		if depth > maxDepth {
+			return filepath.SkipDir
+		}
+		if !pattern.MatchString(cgroup) {
+			return nil
+		}
+		err = fn(cgroup, path)
+		if os.IsNotExist(err) {
+			log.Debugf("cgroups collector: cgroup %s vanished: %s", cgroup, err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't read cgroup %s: %s", cgroup, err)
+		}
+		return nil
+	})
+}
+
+// readCgroupKeyValues reads a flat keyed file such as cpu.stat or
+// memory.events.
+func readCgroupKeyValues(path string) (map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseCgroupKeyValues(f)
+}
+
+func parseCgroupKeyValues(r io.Reader) (map[string]uint64, error) {
+	var (
+		values  = map[string]uint64{}
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid line: %q", scanner.Text())
+		}
+		v, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in line %q: %s", scanner.Text(), err)
+		}
+		values[parts[0]] = v
+	}
+	return values, scanner.Err()
+}
+
+// readCgroupLimit reads a v2 limit file such as memory.max. The returned bool
+// is false if the limit is "max".
+func readCgroupLimit(path string) (uint64, bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "max" {
+		return 0, false, nil
+	}
+	limit, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, err
+	}
+	return limit, true, nil
+}
+
+// selfCgroupDir returns the directory of the cgroup that the node_exporter
+// process is in, as listed in selfCgroupFile, in the hierarchy mounted at
+// root. For v1, the hierarchy of the given controller is used.
+func selfCgroupDir(root, selfCgroupFile, controller string) (string, error) {
+	f, err := os.Open(selfCgroupFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	v2 := isCgroupV2(root)
+	base := root
+	if !v2 {
+		base = filepath.Join(root, controller)
+	}
+
+	var cgroup string
+	found := false
+	scanner := bufio.NewScanner(f)
+	for !found && scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			return "", fmt.Errorf("invalid line in %s: %q", selfCgroupFile, scanner.Text())
+		}
+		if v2 {
+			found = parts[0] == "0" && parts[1] == ""
+		} else {
+			for _, c := range strings.Split(parts[1], ",") {
+				found = found || c == controller
+			}
+		}
+		cgroup = parts[2]
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if !found {
+		return "", fmt.Errorf("no %s cgroup in %s", controller, selfCgroupFile)
+	}
+
+	dir := filepath.Join(base, cgroup)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		// Without a cgroup namespace, containers see the full path of their
+		// cgroup, but only have their own cgroup mounted. Outside of such a
+		// container the root is the wrong cgroup, so make the guess visible.
+		selfCgroupFallbackOnce.Do(func() {
+			log.Warnf("cgroup %s is not mounted, assuming %s is the own cgroup", cgroup, base)
+		})
+		dir = base
+	}
+	return dir, nil
+}
+
+// cgroupMemory contains the memory usage and limit of a cgroup, in bytes.
+type cgroupMemory struct {
+	usage   uint64
+	limit   uint64
+	limited bool
+}
+
+func readSelfCgroupMemory(root, selfCgroupFile string) (*cgroupMemory, error) {
+	dir, err := selfCgroupDir(root, selfCgroupFile, "memory")
+	if err != nil {
+		return nil, err
+	}
+
+	m := &cgroupMemory{}
+	if isCgroupV2(root) {
+		if m.usage, err = readUintFromFile(filepath.Join(dir, "memory.current")); err != nil {
+			return nil, err
+		}
+		if m.limit, m.limited, err = readCgroupLimit(filepath.Join(dir, "memory.max")); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+
+	if m.usage, err = readUintFromFile(filepath.Join(dir, "memory.usage_in_bytes")); err != nil {
+		return nil, err
+	}
+	if m.limit, err = readUintFromFile(filepath.Join(dir, "memory.limit_in_bytes")); err != nil {
+		return nil, err
+	}
+	m.limited = m.limit < cgroupV1MemoryUnlimited
+	return m, nil
+}
+
+// cgroupCPU contains the CPU usage and bandwidth limit of a cgroup, in
+// seconds.
+type cgroupCPU struct {
+	user, system, throttled float64
+	quota, period           float64
+	limited                 bool
+}
+
+func readSelfCgroupCPU(root, selfCgroupFile string) (*cgroupCPU, error) {
+	if isCgroupV2(root) {
+		return readSelfCgroupCPUV2(root, selfCgroupFile)
+	}
+	return readSelfCgroupCPUV1(root, selfCgroupFile)
+}
+
+func readSelfCgroupCPUV2(root, selfCgroupFile string) (*cgroupCPU, error) {
+	dir, err := selfCgroupDir(root, selfCgroupFile, "cpu")
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := readCgroupKeyValues(filepath.Join(dir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	c := &cgroupCPU{
+		user:      float64(stat["user_usec"]) / 1e6,
+		system:    float64(stat["system_usec"]) / 1e6,
+		throttled: float64(stat["throttled_usec"]) / 1e6,
+	}
+
+	// cpu.max holds the quota, or "max", and the period in microseconds. The
+	// root cgroup has no bandwidth control.
+	data, err := ioutil.ReadFile(filepath.Join(dir, "cpu.max"))
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Fields(string(data))
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cpu.max: %q", string(data))
+	}
+	period, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid period in cpu.max: %s", err)
+	}
+	c.period = float64(period) / 1e6
+	if parts[0] != "max" {
+		quota, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quota in cpu.max: %s", err)
+		}
+		c.quota, c.limited = float64(quota)/1e6, true
+	}
+	return c, nil
+}
+
+func readSelfCgroupCPUV1(root, selfCgroupFile string) (*cgroupCPU, error) {
+	cpuacctDir, err := selfCgroupDir(root, selfCgroupFile, "cpuacct")
+	if err != nil {
+		return nil, err
+	}
+	cpuDir, err := selfCgroupDir(root, selfCgroupFile, "cpu")
+	if err != nil {
+		return nil, err
+	}
+
+	acct, err := readCgroupKeyValues(filepath.Join(cpuacctDir, "cpuacct.stat"))
+	if err != nil {
+		return nil, err
+	}
+	stat, err := readCgroupKeyValues(filepath.Join(cpuDir, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	c := &cgroupCPU{
+		user:      float64(acct["user"]) / cgroupUserHZ,
+		system:    float64(acct["system"]) / cgroupUserHZ,
+		throttled: float64(stat["throttled_time"]) / 1e9,
+	}
+
+	// The quota is -1 if there is no limit.
+	data, err := ioutil.ReadFile(filepath.Join(cpuDir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return nil, err
+	}
+	quota, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpu.cfs_quota_us: %s", err)
+	}
+	period, err := readUintFromFile(filepath.Join(cpuDir, "cpu.cfs_period_us"))
+	if err != nil {
+		return nil, err
+	}
+	c.period = float64(period) / 1e6
+	if quota >= 0 {
+		c.quota, c.limited = float64(quota)/1e6, true
+	}
+	return c, nil
+}
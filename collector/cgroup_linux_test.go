@@ -0,0 +1,89 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestSelfCgroupV2(t *testing.T) {
+	root := "fixtures/sys/fs/cgroup"
+
+	m, err := readSelfCgroupMemory(root, "fixtures/proc/self/cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (cgroupMemory{usage: 498073600, limit: 536870912, limited: true}); *m != want {
+		t.Errorf("want memory %+v, got %+v", want, *m)
+	}
+
+	cpu, err := readSelfCgroupCPU(root, "fixtures/proc/self/cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (cgroupCPU{user: 640.132004, system: 271.872817, throttled: 41.937121, quota: 0.2, period: 0.1, limited: true}); *cpu != want {
+		t.Errorf("want cpu %+v, got %+v", want, *cpu)
+	}
+}
+
+func TestSelfCgroupV1(t *testing.T) {
+	f, err := ioutil.TempFile("", "self_cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("4:memory:/system.slice/nginx.service\n3:cpu,cpuacct:/system.slice/nginx.service\n1:name=systemd:/system.slice/nginx.service\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	root := "fixtures/cgroup_v1/fs/cgroup"
+
+	m, err := readSelfCgroupMemory(root, f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (cgroupMemory{usage: 498073600, limit: 536870912, limited: true}); *m != want {
+		t.Errorf("want memory %+v, got %+v", want, *m)
+	}
+
+	cpu, err := readSelfCgroupCPU(root, f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (cgroupCPU{user: 640.13, system: 271.87, throttled: 41.937121, quota: 0.15, period: 0.1, limited: true}); *cpu != want {
+		t.Errorf("want cpu %+v, got %+v", want, *cpu)
+	}
+}
+
+func TestSelfCgroupDirWithoutNamespace(t *testing.T) {
+	f, err := ioutil.TempFile("", "self_cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("0::/kubepods/pod1234/ctr\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	dir, err := selfCgroupDir("fixtures/sys/fs/cgroup", f.Name(), "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "fixtures/sys/fs/cgroup"; dir != want {
+		t.Errorf("want dir %s, got %s", want, dir)
+	}
+}
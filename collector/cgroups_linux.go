@@ -0,0 +1,362 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nocgroups
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	cgroupsSubsystem = "cgroups"
+)
+
+type cgroupsCollector struct {
+	maxDepth    int
+	pathPattern *regexp.Regexp
+
+	cpuUsage, cpuUser, cpuSystem                      typedDesc
+	cpuPeriods, cpuThrottledPeriods, cpuThrottledTime typedDesc
+	memoryUsage, memoryLimit, memoryEvents            typedDesc
+	ioReadBytes, ioWriteBytes, ioReads, ioWrites      typedDesc
+}
+
+func init() {
+	registerCollector("cgroups", defaultDisabled, NewCgroupsCollector)
+}
+
+// NewCgroupsCollector returns a new Collector exposing per-cgroup CPU, memory
+// and IO statistics.
+func NewCgroupsCollector() (Collector, error) {
+	pattern, err := regexp.Compile(*cgroupsPaths)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --collector.cgroups.paths: %s", err)
+	}
+
+	labelNames := []string{"cgroup"}
+	ioLabelNames := []string{"cgroup", "device"}
+	return &cgroupsCollector{
+		maxDepth:    *cgroupsMaxDepth,
+		pathPattern: pattern,
+		cpuUsage: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "cpu_usage_seconds_total"),
+			"Total CPU time consumed by the cgroup in seconds.",
+			labelNames, nil,
+		), prometheus.CounterValue},
+		cpuUser: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "cpu_user_seconds_total"),
+			"CPU time consumed by the cgroup in user mode in seconds.",
+			labelNames, nil,
+		), prometheus.CounterValue},
+		cpuSystem: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "cpu_system_seconds_total"),
+			"CPU time consumed by the cgroup in system mode in seconds.",
+			labelNames, nil,
+		), prometheus.CounterValue},
+		cpuPeriods: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "cpu_periods_total"),
+			"Number of elapsed CPU bandwidth enforcement periods.",
+			labelNames, nil,
+		), prometheus.CounterValue},
+		cpuThrottledPeriods: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "cpu_throttled_periods_total"),
+			"Number of CPU bandwidth enforcement periods in which the cgroup was throttled.",
+			labelNames, nil,
+		), prometheus.CounterValue},
+		cpuThrottledTime: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "cpu_throttled_seconds_total"),
+			"Total time the cgroup was throttled in seconds.",
+			labelNames, nil,
+		), prometheus.CounterValue},
+		memoryUsage: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "memory_usage_bytes"),
+			"Memory currently used by the cgroup in bytes.",
+			labelNames, nil,
+		), prometheus.GaugeValue},
+		memoryLimit: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "memory_limit_bytes"),
+			"Memory limit of the cgroup in bytes, not reported if unlimited.",
+			labelNames, nil,
+		), prometheus.GaugeValue},
+		memoryEvents: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "memory_events_total"),
+			"Number of memory events of the cgroup by type.",
+			[]string{"cgroup", "event"}, nil,
+		), prometheus.CounterValue},
+		ioReadBytes: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "io_read_bytes_total"),
+			"Number of bytes read by the cgroup from the device.",
+			ioLabelNames, nil,
+		), prometheus.CounterValue},
+		ioWriteBytes: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "io_written_bytes_total"),
+			"Number of bytes written by the cgroup to the device.",
+			ioLabelNames, nil,
+		), prometheus.CounterValue},
+		ioReads: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "io_reads_total"),
+			"Number of read operations issued by the cgroup to the device.",
+			ioLabelNames, nil,
+		), prometheus.CounterValue},
+		ioWrites: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cgroupsSubsystem, "io_writes_total"),
+			"Number of write operations issued by the cgroup to the device.",
+			ioLabelNames, nil,
+		), prometheus.CounterValue},
+	}, nil
+}
+
+func (c *cgroupsCollector) Update(ch chan<- prometheus.Metric) error {
+	root := sysFilePath("fs/cgroup")
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		log.Debugf("cgroups collector: %s does not exist", root)
+		return nil
+	}
+
+	if isCgroupV2(root) {
+		return c.updateV2(ch, root)
+	}
+	return c.updateV1(ch, root)
+}
+
+func (c *cgroupsCollector) updateV2(ch chan<- prometheus.Metric, root string) error {
+	return walkCgroups(root, c.maxDepth, c.pathPattern, func(cgroup, dir string) error {
+		if stat, err := readCgroupKeyValues(filepath.Join(dir, "cpu.stat")); err == nil {
+			ch <- c.cpuUsage.mustNewConstMetric(float64(stat["usage_usec"])/1e6, cgroup)
+			ch <- c.cpuUser.mustNewConstMetric(float64(stat["user_usec"])/1e6, cgroup)
+			ch <- c.cpuSystem.mustNewConstMetric(float64(stat["system_usec"])/1e6, cgroup)
+			// The root cgroup has no bandwidth control.
+			if _, ok := stat["nr_periods"]; ok {
+				ch <- c.cpuPeriods.mustNewConstMetric(float64(stat["nr_periods"]), cgroup)
+				ch <- c.cpuThrottledPeriods.mustNewConstMetric(float64(stat["nr_throttled"]), cgroup)
+				ch <- c.cpuThrottledTime.mustNewConstMetric(float64(stat["throttled_usec"])/1e6, cgroup)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		if usage, err := readUintFromFile(filepath.Join(dir, "memory.current")); err == nil {
+			ch <- c.memoryUsage.mustNewConstMetric(float64(usage), cgroup)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if limit, ok, err := readCgroupLimit(filepath.Join(dir, "memory.max")); err == nil {
+			if ok {
+				ch <- c.memoryLimit.mustNewConstMetric(float64(limit), cgroup)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		if events, err := readCgroupKeyValues(filepath.Join(dir, "memory.events")); err == nil {
+			for event, v := range events {
+				ch <- c.memoryEvents.mustNewConstMetric(float64(v), cgroup, event)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		if stats, err := readCgroupIOStat(filepath.Join(dir, "io.stat")); err == nil {
+			for device, stat := range stats {
+				ch <- c.ioReadBytes.mustNewConstMetric(float64(stat["rbytes"]), cgroup, device)
+				ch <- c.ioWriteBytes.mustNewConstMetric(float64(stat["wbytes"]), cgroup, device)
+				ch <- c.ioReads.mustNewConstMetric(float64(stat["rios"]), cgroup, device)
+				ch <- c.ioWrites.mustNewConstMetric(float64(stat["wios"]), cgroup, device)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+func (c *cgroupsCollector) updateV1(ch chan<- prometheus.Metric, root string) error {
+	err := walkCgroupController(root, "cpuacct", c.maxDepth, c.pathPattern, func(cgroup, dir string) error {
+		usage, err := readUintFromFile(filepath.Join(dir, "cpuacct.usage"))
+		if err != nil {
+			return err
+		}
+		ch <- c.cpuUsage.mustNewConstMetric(float64(usage)/1e9, cgroup)
+
+		stat, err := readCgroupKeyValues(filepath.Join(dir, "cpuacct.stat"))
+		if err != nil {
+			return err
+		}
+		ch <- c.cpuUser.mustNewConstMetric(float64(stat["user"])/cgroupUserHZ, cgroup)
+		ch <- c.cpuSystem.mustNewConstMetric(float64(stat["system"])/cgroupUserHZ, cgroup)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkCgroupController(root, "cpu", c.maxDepth, c.pathPattern, func(cgroup, dir string) error {
+		stat, err := readCgroupKeyValues(filepath.Join(dir, "cpu.stat"))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		ch <- c.cpuPeriods.mustNewConstMetric(float64(stat["nr_periods"]), cgroup)
+		ch <- c.cpuThrottledPeriods.mustNewConstMetric(float64(stat["nr_throttled"]), cgroup)
+		ch <- c.cpuThrottledTime.mustNewConstMetric(float64(stat["throttled_time"])/1e9, cgroup)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = walkCgroupController(root, "memory", c.maxDepth, c.pathPattern, func(cgroup, dir string) error {
+		usage, err := readUintFromFile(filepath.Join(dir, "memory.usage_in_bytes"))
+		if err != nil {
+			return err
+		}
+		ch <- c.memoryUsage.mustNewConstMetric(float64(usage), cgroup)
+
+		limit, err := readUintFromFile(filepath.Join(dir, "memory.limit_in_bytes"))
+		if err != nil {
+			return err
+		}
+		if limit < cgroupV1MemoryUnlimited {
+			ch <- c.memoryLimit.mustNewConstMetric(float64(limit), cgroup)
+		}
+
+		// The closest v1 equivalent to the v2 "max" event is the number of
+		// times the usage hit the limit.
+		failcnt, err := readUintFromFile(filepath.Join(dir, "memory.failcnt"))
+		if err != nil {
+			return err
+		}
+		ch <- c.memoryEvents.mustNewConstMetric(float64(failcnt), cgroup, "max")
+
+		// oom_kill is only reported since Linux 4.13.
+		oomControl, err := readCgroupKeyValues(filepath.Join(dir, "memory.oom_control"))
+		if err != nil {
+			return err
+		}
+		if v, ok := oomControl["oom_kill"]; ok {
+			ch <- c.memoryEvents.mustNewConstMetric(float64(v), cgroup, "oom_kill")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return walkCgroupController(root, "blkio", c.maxDepth, c.pathPattern, func(cgroup, dir string) error {
+		bytes, err := readCgroupBlkioStat(filepath.Join(dir, "blkio.throttle.io_service_bytes"))
+		if err != nil {
+			return err
+		}
+		ops, err := readCgroupBlkioStat(filepath.Join(dir, "blkio.throttle.io_serviced"))
+		if err != nil {
+			return err
+		}
+		for device, stat := range bytes {
+			ch <- c.ioReadBytes.mustNewConstMetric(float64(stat["Read"]), cgroup, device)
+			ch <- c.ioWriteBytes.mustNewConstMetric(float64(stat["Write"]), cgroup, device)
+		}
+		for device, stat := range ops {
+			ch <- c.ioReads.mustNewConstMetric(float64(stat["Read"]), cgroup, device)
+			ch <- c.ioWrites.mustNewConstMetric(float64(stat["Write"]), cgroup, device)
+		}
+		return nil
+	})
+}
+
+// readCgroupIOStat reads a v2 io.stat file, returning the statistics by
+// major:minor device number.
+func readCgroupIOStat(path string) (map[string]map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseCgroupIOStat(f)
+}
+
+func parseCgroupIOStat(r io.Reader) (map[string]map[string]uint64, error) {
+	var (
+		stats   = map[string]map[string]uint64{}
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) == 0 {
+			continue
+		}
+		stat := map[string]uint64{}
+		for _, kv := range parts[1:] {
+			i := strings.Index(kv, "=")
+			if i < 0 {
+				return nil, fmt.Errorf("invalid field %q in line %q", kv, scanner.Text())
+			}
+			v, err := strconv.ParseUint(kv[i+1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in line %q: %s", scanner.Text(), err)
+			}
+			stat[kv[:i]] = v
+		}
+		stats[parts[0]] = stat
+	}
+	return stats, scanner.Err()
+}
+
+// readCgroupBlkioStat reads a v1 blkio statistics file such as
+// blkio.throttle.io_service_bytes, returning the statistics by major:minor
+// device number.
+func readCgroupBlkioStat(path string) (map[string]map[string]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseCgroupBlkioStat(f)
+}
+
+func parseCgroupBlkioStat(r io.Reader) (map[string]map[string]uint64, error) {
+	var (
+		stats   = map[string]map[string]uint64{}
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		// Skip the summary line "Total <n>".
+		if len(parts) != 3 {
+			continue
+		}
+		v, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in line %q: %s", scanner.Text(), err)
+		}
+		if stats[parts[0]] == nil {
+			stats[parts[0]] = map[string]uint64{}
+		}
+		stats[parts[0]][parts[1]] = v
+	}
+	return stats, scanner.Err()
+}
@@ -0,0 +1,134 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nocgroups
+
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+// collectValues runs the collector and returns the values of all metrics
+// keyed by the metric name followed by its label values, e.g.
+// `node_cgroups_memory_usage_bytes{/system.slice}`.
+func collectValues(t *testing.T, c Collector) map[string]float64 {
+	ch := make(chan prometheus.Metric)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- c.Update(ch)
+		close(ch)
+	}()
+
+	values := map[string]float64{}
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		var labels []string
+		for _, l := range pb.GetLabel() {
+			labels = append(labels, l.GetValue())
+		}
+		desc := m.Desc().String()
+		name := desc[strings.Index(desc, `fqName: "`)+9:]
+		name = name[:strings.Index(name, `"`)]
+		key := name + "{" + strings.Join(labels, ",") + "}"
+
+		switch {
+		case pb.Gauge != nil:
+			values[key] = pb.GetGauge().GetValue()
+		case pb.Counter != nil:
+			values[key] = pb.GetCounter().GetValue()
+		default:
+			values[key] = pb.GetUntyped().GetValue()
+		}
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	return values
+}
+
+func TestCgroupsV2(t *testing.T) {
+	if _, err := kingpin.CommandLine.Parse([]string{"--path.sysfs", "fixtures/sys"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCgroupsCollector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := collectValues(t, c)
+
+	for key, want := range map[string]float64{
+		"node_cgroups_cpu_usage_seconds_total{/}":                                3453.87123,
+		"node_cgroups_cpu_throttled_periods_total{/system.slice/nginx.service}":  1274,
+		"node_cgroups_cpu_throttled_seconds_total{/system.slice/nginx.service}":  41.937121,
+		"node_cgroups_memory_usage_bytes{/system.slice/nginx.service}":           498073600,
+		"node_cgroups_memory_limit_bytes{/system.slice/nginx.service}":           536870912,
+		"node_cgroups_memory_events_total{/system.slice/nginx.service,oom_kill}": 2,
+		"node_cgroups_io_read_bytes_total{/system.slice,253:0}":                  524288,
+		"node_cgroups_io_writes_total{/system.slice/nginx.service,8:0}":          10320,
+	} {
+		if got, ok := values[key]; !ok || got != want {
+			t.Errorf("%s: want %f, got %f (present: %t)", key, want, got, ok)
+		}
+	}
+	for _, key := range []string{
+		// The root cgroup has no bandwidth control.
+		"node_cgroups_cpu_periods_total{/}",
+		// Unlimited.
+		"node_cgroups_memory_limit_bytes{/system.slice}",
+		// Deeper than --collector.cgroups.max-depth.
+		"node_cgroups_cpu_usage_seconds_total{/user.slice/user-1000.slice/user@1000.service}",
+	} {
+		if _, ok := values[key]; ok {
+			t.Errorf("%s: unexpected metric", key)
+		}
+	}
+}
+
+func TestCgroupsV1(t *testing.T) {
+	if _, err := kingpin.CommandLine.Parse([]string{"--path.sysfs", "fixtures/cgroup_v1"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewCgroupsCollector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := collectValues(t, c)
+
+	for key, want := range map[string]float64{
+		"node_cgroups_cpu_usage_seconds_total{/system.slice/nginx.service}":      9120.04821,
+		"node_cgroups_cpu_user_seconds_total{/system.slice/nginx.service}":       640.13,
+		"node_cgroups_cpu_throttled_seconds_total{/system.slice/nginx.service}":  41.937121,
+		"node_cgroups_memory_usage_bytes{/}":                                     8589934592,
+		"node_cgroups_memory_limit_bytes{/system.slice/nginx.service}":           536870912,
+		"node_cgroups_memory_events_total{/system.slice/nginx.service,max}":      117,
+		"node_cgroups_memory_events_total{/system.slice/nginx.service,oom_kill}": 2,
+		"node_cgroups_io_written_bytes_total{/system.slice/nginx.service,8:0}":   104857600,
+		"node_cgroups_io_reads_total{/,8:0}":                                     52341,
+	} {
+		if got, ok := values[key]; !ok || got != want {
+			t.Errorf("%s: want %f, got %f (present: %t)", key, want, got, ok)
+		}
+	}
+	if _, ok := values["node_cgroups_memory_limit_bytes{/}"]; ok {
+		t.Error("unexpected memory limit for unlimited root cgroup")
+	}
+}
@@ -0,0 +1,370 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nocifs
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+var (
+	cifsShareRE      = regexp.MustCompile(`^\d+\) \\\\([^\\]+)\\(.*?)(\s+DISCONNECTED)?\s*$`)
+	cifsReconnectsRE = regexp.MustCompile(`^(\d+) session (\d+) share reconnects$`)
+	cifsVFSOpsRE     = regexp.MustCompile(`^Total vfs operations: (\d+) maximum at one time: (\d+)$`)
+	cifsBytesRE      = regexp.MustCompile(`^Bytes read: (\d+)\s+Bytes written: (\d+)$`)
+	cifsOpenFilesRE  = regexp.MustCompile(`^Open files: (\d+) total \(local\), (\d+) open on server$`)
+	// SMB2/3 operations, oplock breaks are counted as sent.
+	cifsSMB2OpRE = regexp.MustCompile(`^(\w+): (\d+) (?:total|sent) (\d+) failed$`)
+)
+
+// cifsStats contains the statistics from /proc/fs/cifs/Stats.
+type cifsStats struct {
+	// Number of SMB sessions.
+	Sessions uint64
+	// Number of unique mount targets.
+	Shares uint64
+	// Number of times a session or share was reconnected.
+	SessionReconnects uint64
+	ShareReconnects   uint64
+	// Number of VFS operations and the maximum number of them in progress at
+	// the same time.
+	VFSOperations    uint64
+	VFSOperationsMax uint64
+	ShareStats       []cifsShareStats
+}
+
+// cifsShareStats contains the statistics of a mounted share. Only SMB2/3
+// shares report open files and failed operations.
+type cifsShareStats struct {
+	Server       string
+	Share        string
+	Disconnected bool
+	SMBs         uint64
+	BytesRead    uint64
+	BytesWritten uint64
+	// Files opened locally and on the server.
+	OpenFiles       uint64
+	ServerOpenFiles uint64
+	// Operations by lower case name.
+	Operations       map[string]uint64
+	FailedOperations map[string]uint64
+}
+
+type cifsCollector struct {
+	sessions, shares, sessionReconnects, shareReconnects typedDesc
+	vfsOperations, vfsOperationsMax                      typedDesc
+	shareInfo, smbs, bytesRead, bytesWritten             typedDesc
+	openFiles, serverOpenFiles, operations, failedOps    typedDesc
+}
+
+func init() {
+	registerCollector("cifs", defaultDisabled, NewCIFSCollector)
+}
+
+// NewCIFSCollector returns a new Collector exposing CIFS client statistics.
+func NewCIFSCollector() (Collector, error) {
+	subsystem := "cifs"
+	shareLabels := []string{"server", "share"}
+	return &cifsCollector{
+		sessions: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "sessions"),
+			"Number of SMB sessions.",
+			nil, nil,
+		), prometheus.GaugeValue},
+		shares: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "shares"),
+			"Number of unique mounted shares.",
+			nil, nil,
+		), prometheus.GaugeValue},
+		sessionReconnects: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "session_reconnects_total"),
+			"Number of times an SMB session was reconnected.",
+			nil, nil,
+		), prometheus.CounterValue},
+		shareReconnects: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "share_reconnects_total"),
+			"Number of times a share was reconnected.",
+			nil, nil,
+		), prometheus.CounterValue},
+		vfsOperations: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "vfs_operations_total"),
+			"Number of VFS operations on CIFS mounts.",
+			nil, nil,
+		), prometheus.CounterValue},
+		vfsOperationsMax: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "vfs_operations_max"),
+			"Maximum number of VFS operations in progress at the same time.",
+			nil, nil,
+		), prometheus.GaugeValue},
+		shareInfo: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "share_connected"),
+			"Whether all connections to the share are connected.",
+			shareLabels, nil,
+		), prometheus.GaugeValue},
+		smbs: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "smbs_total"),
+			"Number of SMBs sent for the share.",
+			shareLabels, nil,
+		), prometheus.CounterValue},
+		bytesRead: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "read_bytes_total"),
+			"Number of bytes read from the share.",
+			shareLabels, nil,
+		), prometheus.CounterValue},
+		bytesWritten: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "written_bytes_total"),
+			"Number of bytes written to the share.",
+			shareLabels, nil,
+		), prometheus.CounterValue},
+		openFiles: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "open_files"),
+			"Number of files opened locally on the share, only reported for SMB2/3.",
+			shareLabels, nil,
+		), prometheus.GaugeValue},
+		serverOpenFiles: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "server_open_files"),
+			"Number of files open on the server, only reported for SMB2/3.",
+			shareLabels, nil,
+		), prometheus.GaugeValue},
+		operations: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "operations_total"),
+			"Number of operations on the share.",
+			[]string{"server", "share", "operation"}, nil,
+		), prometheus.CounterValue},
+		failedOps: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "operations_failed_total"),
+			"Number of failed operations on the share, only reported for SMB2/3.",
+			[]string{"server", "share", "operation"}, nil,
+		), prometheus.CounterValue},
+	}, nil
+}
+
+func (c *cifsCollector) Update(ch chan<- prometheus.Metric) error {
+	f, err := os.Open(procFilePath("fs/cifs/Stats"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Debug("cifs collector: CIFS module is not loaded")
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	stats, err := parseCIFSStats(f)
+	if err != nil {
+		return fmt.Errorf("couldn't parse CIFS stats: %s", err)
+	}
+
+	ch <- c.sessions.mustNewConstMetric(float64(stats.Sessions))
+	ch <- c.shares.mustNewConstMetric(float64(stats.Shares))
+	ch <- c.sessionReconnects.mustNewConstMetric(float64(stats.SessionReconnects))
+	ch <- c.shareReconnects.mustNewConstMetric(float64(stats.ShareReconnects))
+	ch <- c.vfsOperations.mustNewConstMetric(float64(stats.VFSOperations))
+	ch <- c.vfsOperationsMax.mustNewConstMetric(float64(stats.VFSOperationsMax))
+
+	// A share that is mounted several times, for example with different
+	// credentials, is listed once per connection. Sum them up to keep the
+	// series unique.
+	for _, s := range mergeCIFSShares(stats.ShareStats) {
+		connected := 1.0
+		if s.Disconnected {
+			connected = 0
+		}
+		ch <- c.shareInfo.mustNewConstMetric(connected, s.Server, s.Share)
+		ch <- c.smbs.mustNewConstMetric(float64(s.SMBs), s.Server, s.Share)
+		ch <- c.bytesRead.mustNewConstMetric(float64(s.BytesRead), s.Server, s.Share)
+		ch <- c.bytesWritten.mustNewConstMetric(float64(s.BytesWritten), s.Server, s.Share)
+		if s.FailedOperations != nil {
+			ch <- c.openFiles.mustNewConstMetric(float64(s.OpenFiles), s.Server, s.Share)
+			ch <- c.serverOpenFiles.mustNewConstMetric(float64(s.ServerOpenFiles), s.Server, s.Share)
+		}
+		for op, v := range s.Operations {
+			ch <- c.operations.mustNewConstMetric(float64(v), s.Server, s.Share, op)
+		}
+		for op, v := range s.FailedOperations {
+			ch <- c.failedOps.mustNewConstMetric(float64(v), s.Server, s.Share, op)
+		}
+	}
+	return nil
+}
+
+// mergeCIFSShares sums up the statistics of shares that are listed more than
+// once, keeping the order of their first occurrence. A merged share counts as
+// disconnected if any of its connections is.
+func mergeCIFSShares(shares []cifsShareStats) []cifsShareStats {
+	var (
+		merged []cifsShareStats
+		index  = map[[2]string]int{}
+	)
+	for _, s := range shares {
+		key := [2]string{s.Server, s.Share}
+		i, ok := index[key]
+		if !ok {
+			i = len(merged)
+			index[key] = i
+			merged = append(merged, cifsShareStats{
+				Server:     s.Server,
+				Share:      s.Share,
+				Operations: map[string]uint64{},
+			})
+		}
+		m := &merged[i]
+		m.Disconnected = m.Disconnected || s.Disconnected
+		m.SMBs += s.SMBs
+		m.BytesRead += s.BytesRead
+		m.BytesWritten += s.BytesWritten
+		m.OpenFiles += s.OpenFiles
+		m.ServerOpenFiles += s.ServerOpenFiles
+		for op, v := range s.Operations {
+			m.Operations[op] += v
+		}
+		if s.FailedOperations != nil && m.FailedOperations == nil {
+			m.FailedOperations = map[string]uint64{}
+		}
+		for op, v := range s.FailedOperations {
+			m.FailedOperations[op] += v
+		}
+	}
+	return merged
+}
+
+// parseCIFSStats parses the contents of /proc/fs/cifs/Stats, which lists the
+// shares in the SMB1 or the SMB2/3 format depending on the dialect in use.
+func parseCIFSStats(r io.Reader) (*cifsStats, error) {
+	stats := &cifsStats{}
+	var share *cifsShareStats
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if m := cifsShareRE.FindStringSubmatch(scanner.Text()); m != nil {
+			stats.ShareStats = append(stats.ShareStats, cifsShareStats{
+				Server:       m[1],
+				Share:        m[2],
+				Disconnected: m[3] != "",
+				Operations:   map[string]uint64{},
+			})
+			share = &stats.ShareStats[len(stats.ShareStats)-1]
+			continue
+		}
+
+		if share == nil {
+			if err := parseCIFSGlobalLine(line, stats); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := parseCIFSShareLine(line, share); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func parseCIFSGlobalLine(line string, stats *cifsStats) error {
+	var (
+		values []string
+		dst    []*uint64
+	)
+	switch {
+	case strings.HasPrefix(line, "CIFS Session:"):
+		values, dst = strings.Fields(line)[2:], []*uint64{&stats.Sessions}
+	case strings.HasPrefix(line, "Share (unique mount targets):"):
+		values, dst = strings.Fields(line)[4:], []*uint64{&stats.Shares}
+	case cifsReconnectsRE.MatchString(line):
+		values, dst = cifsReconnectsRE.FindStringSubmatch(line)[1:], []*uint64{&stats.SessionReconnects, &stats.ShareReconnects}
+	case cifsVFSOpsRE.MatchString(line):
+		values, dst = cifsVFSOpsRE.FindStringSubmatch(line)[1:], []*uint64{&stats.VFSOperations, &stats.VFSOperationsMax}
+	}
+	return parseCIFSValues(line, values, dst)
+}
+
+func parseCIFSShareLine(line string, share *cifsShareStats) error {
+	if m := cifsBytesRE.FindStringSubmatch(line); m != nil {
+		return parseCIFSValues(line, m[1:], []*uint64{&share.BytesRead, &share.BytesWritten})
+	}
+	if m := cifsOpenFilesRE.FindStringSubmatch(line); m != nil {
+		return parseCIFSValues(line, m[1:], []*uint64{&share.OpenFiles, &share.ServerOpenFiles})
+	}
+	if m := cifsSMB2OpRE.FindStringSubmatch(line); m != nil {
+		if share.FailedOperations == nil {
+			share.FailedOperations = map[string]uint64{}
+		}
+		op := strings.ToLower(m[1])
+		var total, failed uint64
+		if err := parseCIFSValues(line, m[2:], []*uint64{&total, &failed}); err != nil {
+			return err
+		}
+		share.Operations[op] = total
+		share.FailedOperations[op] = failed
+		return nil
+	}
+
+	// SMB1 lines hold several counters, such as "Opens: 9 Closes: 8" or
+	// "FindFirst: 4 FNext 0 FClose 0". The byte counts follow the number of
+	// reads and writes.
+	var name, prev string
+	for _, field := range strings.Fields(line) {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			name = strings.TrimPrefix(name+"_"+strings.TrimSuffix(strings.ToLower(field), ":"), "_")
+			continue
+		}
+		switch {
+		case name == "smbs":
+			share.SMBs = v
+		case name == "bytes" && prev == "reads":
+			share.BytesRead = v
+		case name == "bytes" && prev == "writes":
+			share.BytesWritten = v
+		case name != "":
+			share.Operations[name] = v
+		default:
+			return fmt.Errorf("unexpected value in line %q", line)
+		}
+		prev, name = name, ""
+	}
+	return nil
+}
+
+func parseCIFSValues(line string, values []string, dst []*uint64) error {
+	if len(values) < len(dst) {
+		return fmt.Errorf("invalid line %q", line)
+	}
+	for i := range dst {
+		v, err := strconv.ParseUint(values[i], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value in line %q: %s", line, err)
+		}
+		*dst[i] = v
+	}
+	return nil
+}
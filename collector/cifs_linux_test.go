@@ -0,0 +1,97 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nocifs
+
+package collector
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCIFSStats(t *testing.T) {
+	f, err := os.Open("fixtures/proc/fs/cifs/Stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stats, err := parseCIFSStats(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Sessions != 3 || stats.Shares != 4 || stats.SessionReconnects != 3 || stats.ShareReconnects != 1 ||
+		stats.VFSOperations != 1643 || stats.VFSOperationsMax != 4 {
+		t.Errorf("unexpected global stats %+v", *stats)
+	}
+	if want, got := 4, len(stats.ShareStats); want != got {
+		t.Fatalf("want %d shares, got %d", want, got)
+	}
+
+	smb2 := stats.ShareStats[0]
+	if smb2.Server != "fileserver1.example.com" || smb2.Share != "share1" || smb2.Disconnected {
+		t.Errorf("unexpected share %q on %q", smb2.Share, smb2.Server)
+	}
+	if smb2.SMBs != 1402 || smb2.BytesRead != 10503542 || smb2.BytesWritten != 2219476 || smb2.OpenFiles != 3 || smb2.ServerOpenFiles != 2 {
+		t.Errorf("unexpected SMB2 share stats %+v", smb2)
+	}
+	if smb2.Operations["creates"] != 412 || smb2.FailedOperations["creates"] != 3 || smb2.Operations["oplockbreaks"] != 2 {
+		t.Errorf("unexpected SMB2 operations %v, failed %v", smb2.Operations, smb2.FailedOperations)
+	}
+
+	smb1 := stats.ShareStats[2]
+	if smb1.Server != "legacy" || smb1.Share != "public" || !smb1.Disconnected {
+		t.Errorf("unexpected share %q on %q", smb1.Share, smb1.Server)
+	}
+	if smb1.SMBs != 81 || smb1.BytesRead != 28672 || smb1.BytesWritten != 1024 || smb1.FailedOperations != nil {
+		t.Errorf("unexpected SMB1 share stats %+v", smb1)
+	}
+	for op, want := range map[string]uint64{"reads": 7, "opens": 9, "t2_renames": 0, "posix_mkdirs": 0, "fnext": 0, "deletes": 1} {
+		if got, ok := smb1.Operations[op]; !ok || got != want {
+			t.Errorf("want %d %s operations, got %d", want, op, got)
+		}
+	}
+}
+
+func TestMergeCIFSShares(t *testing.T) {
+	f, err := os.Open("fixtures/proc/fs/cifs/Stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	stats, err := parseCIFSStats(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shares := mergeCIFSShares(stats.ShareStats)
+	if want, got := 3, len(shares); want != got {
+		t.Fatalf("want %d shares, got %d", want, got)
+	}
+	share1 := shares[0]
+	if share1.Server != "fileserver1.example.com" || share1.Share != "share1" {
+		t.Errorf("unexpected share %q on %q", share1.Share, share1.Server)
+	}
+	if share1.SMBs != 1439 || share1.BytesRead != 10569078 || share1.OpenFiles != 4 || share1.ServerOpenFiles != 3 {
+		t.Errorf("unexpected merged share stats %+v", share1)
+	}
+	if share1.Operations["creates"] != 426 || share1.FailedOperations["creates"] != 4 {
+		t.Errorf("unexpected merged operations %v, failed %v", share1.Operations, share1.FailedOperations)
+	}
+	if legacy := shares[2]; legacy.Share != "public" || !legacy.Disconnected || legacy.FailedOperations != nil {
+		t.Errorf("unexpected SMB1 share stats %+v", legacy)
+	}
+}
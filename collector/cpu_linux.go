@@ -35,6 +35,9 @@ type cpuCollector struct {
 	cpuFreqMax         *prometheus.Desc
 	cpuCoreThrottle    *prometheus.Desc
 	cpuPackageThrottle *prometheus.Desc
+	cgroupLimit        *prometheus.Desc
+	cgroupSeconds      *prometheus.Desc
+	cgroupThrottled    *prometheus.Desc
 }
 
 func init() {
@@ -75,6 +78,21 @@ func NewCPUCollector() (Collector, error) {
 			"Number of times this cpu package has been throttled.",
 			[]string{"package"}, nil,
 		),
+		cgroupLimit: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cpuCollectorSubsystem, "cgroup_limit_cpus"),
+			"Number of cpus the cgroup of node_exporter may use per period.",
+			nil, nil,
+		),
+		cgroupSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cpuCollectorSubsystem, "cgroup_seconds_total"),
+			"Seconds the cgroup of node_exporter spent in each mode.",
+			[]string{"mode"}, nil,
+		),
+		cgroupThrottled: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, cpuCollectorSubsystem, "cgroup_throttled_seconds_total"),
+			"Seconds the cgroup of node_exporter was throttled for exceeding its quota.",
+			nil, nil,
+		),
 	}, nil
 }
 
@@ -86,6 +104,27 @@ func (c *cpuCollector) Update(ch chan<- prometheus.Metric) error {
 	if err := c.updateCPUfreq(ch); err != nil {
 		return err
 	}
+	if *selfCgroup {
+		// The cgroup metrics are an addition, don't fail the whole collector.
+		if err := c.updateCgroup(ch); err != nil {
+			log.Errorf("cpu collector: %s", err)
+		}
+	}
+	return nil
+}
+
+// updateCgroup exposes the usage and quota of the cgroup node_exporter runs in.
+func (c *cpuCollector) updateCgroup(ch chan<- prometheus.Metric) error {
+	cpu, err := readSelfCgroupCPU(sysFilePath("fs/cgroup"), procFilePath("self/cgroup"))
+	if err != nil {
+		return fmt.Errorf("couldn't get cpu usage of own cgroup: %s", err)
+	}
+	ch <- prometheus.MustNewConstMetric(c.cgroupSeconds, prometheus.CounterValue, cpu.user, "user")
+	ch <- prometheus.MustNewConstMetric(c.cgroupSeconds, prometheus.CounterValue, cpu.system, "system")
+	ch <- prometheus.MustNewConstMetric(c.cgroupThrottled, prometheus.CounterValue, cpu.throttled)
+	if cpu.limited && cpu.period > 0 {
+		ch <- prometheus.MustNewConstMetric(c.cgroupLimit, prometheus.GaugeValue, cpu.quota/cpu.period)
+	}
 	return nil
 }
 
@@ -0,0 +1,429 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DRBD 9 no longer reports per-resource statistics in /proc/drbd. They are
+// read from the proc_drbd file debugfs has for every volume and peer of a
+// resource, or from the output of `drbdsetup events2 --now --statistics`.
+
+var (
+	drbd9DeviceLabels     = []string{"device", "resource", "volume"}
+	drbd9PeerDeviceLabels = []string{"device", "resource", "volume", "peer"}
+
+	drbd9DiskWritten = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "disk_written_bytes_total"),
+		"Net data written on local hard disk; in bytes.",
+		drbd9DeviceLabels, nil)
+	drbd9DiskRead = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "disk_read_bytes_total"),
+		"Net data read from local hard disk; in bytes.",
+		drbd9DeviceLabels, nil)
+	drbd9ActivityLogWrites = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "activitylog_writes_total"),
+		"Number of updates of the activity log area of the meta data.",
+		drbd9DeviceLabels, nil)
+	drbd9BitmapWrites = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "bitmap_writes_total"),
+		"Number of updates of the bitmap area of the meta data.",
+		drbd9DeviceLabels, nil)
+	drbd9LocalPending = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "local_pending"),
+		"Number of open requests to the local I/O sub-system.",
+		drbd9DeviceLabels, nil)
+	drbd9ApplicationPending = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "application_pending"),
+		"Number of block I/O requests forwarded to DRBD, but not yet answered by DRBD.",
+		drbd9DeviceLabels, nil)
+	drbd9DiskUpToDate = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "disk_state_is_up_to_date"),
+		"Whether the disk of the node is up to date.",
+		[]string{"device", "resource", "volume", "node"}, nil)
+
+	drbd9NetworkSent = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "network_sent_bytes_total"),
+		"Total number of bytes sent via the network.",
+		drbd9PeerDeviceLabels, nil)
+	drbd9NetworkReceived = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "network_received_bytes_total"),
+		"Total number of bytes received via the network.",
+		drbd9PeerDeviceLabels, nil)
+	drbd9RemotePending = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "remote_pending"),
+		"Number of requests sent to the peer, but that have not yet been answered by the latter.",
+		drbd9PeerDeviceLabels, nil)
+	drbd9RemoteUnacknowledged = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "remote_unacknowledged"),
+		"Number of requests received by the peer via the network connection, but that have not yet been answered.",
+		drbd9PeerDeviceLabels, nil)
+	drbd9OutOfSync = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "out_of_sync_bytes"),
+		"Amount of data known to be out of sync; in bytes.",
+		drbd9PeerDeviceLabels, nil)
+
+	drbd9RoleIsPrimary = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "node_role_is_primary"),
+		"Whether the role of the node is in the primary state.",
+		[]string{"resource", "node"}, nil)
+	drbd9Connected = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "drbd", "connected"),
+		"Whether DRBD is connected to the peer.",
+		[]string{"resource", "peer"}, nil)
+
+	// drbd9ReplicationStates are shown instead of the connection state by
+	// proc_drbd once a peer is connected.
+	drbd9ReplicationStates = map[string]bool{
+		"Off": true, "Established": true, "StartingSyncS": true, "StartingSyncT": true,
+		"WFBitMapS": true, "WFBitMapT": true, "WFSyncUUID": true, "SyncSource": true,
+		"SyncTarget": true, "VerifyS": true, "VerifyT": true, "PausedSyncS": true,
+		"PausedSyncT": true, "Ahead": true, "Behind": true,
+	}
+)
+
+type drbd9Connection struct {
+	resource, peer string
+	state          string
+	peerRole       string
+}
+
+type drbd9Device struct {
+	resource, volume, minor string
+	disk                    string
+	// Amounts of data are in KiB.
+	read, written              float64
+	alWrites, bmWrites         float64
+	lowerPending, upperPending float64
+	peerDevices                []*drbd9PeerDevice
+	peerDeviceIndex            map[string]*drbd9PeerDevice
+}
+
+type drbd9PeerDevice struct {
+	peer             string
+	peerDisk         string
+	sent, received   float64
+	outOfSync        float64
+	pending, unacked float64
+}
+
+// drbd9Stats holds the state of all DRBD 9 resources.
+type drbd9Stats struct {
+	// Local role by resource.
+	roles       map[string]string
+	connections []*drbd9Connection
+	devices     []*drbd9Device
+
+	connectionIndex map[string]*drbd9Connection
+	deviceIndex     map[string]*drbd9Device
+}
+
+func newDRBD9Stats() *drbd9Stats {
+	return &drbd9Stats{
+		roles:           map[string]string{},
+		connectionIndex: map[string]*drbd9Connection{},
+		deviceIndex:     map[string]*drbd9Device{},
+	}
+}
+
+func (s *drbd9Stats) connection(resource, peer string) *drbd9Connection {
+	key := resource + "/" + peer
+	if c, ok := s.connectionIndex[key]; ok {
+		return c
+	}
+	c := &drbd9Connection{resource: resource, peer: peer}
+	s.connectionIndex[key] = c
+	s.connections = append(s.connections, c)
+	return c
+}
+
+func (s *drbd9Stats) device(resource, volume string) *drbd9Device {
+	key := resource + "/" + volume
+	if d, ok := s.deviceIndex[key]; ok {
+		return d
+	}
+	d := &drbd9Device{resource: resource, volume: volume, peerDeviceIndex: map[string]*drbd9PeerDevice{}}
+	s.deviceIndex[key] = d
+	s.devices = append(s.devices, d)
+	return d
+}
+
+func (d *drbd9Device) peerDevice(peer string) *drbd9PeerDevice {
+	if p, ok := d.peerDeviceIndex[peer]; ok {
+		return p
+	}
+	p := &drbd9PeerDevice{peer: peer}
+	d.peerDeviceIndex[peer] = p
+	d.peerDevices = append(d.peerDevices, p)
+	return p
+}
+
+// readDRBD9DebugFS reads the proc_drbd files below
+// <root>/<resource>/connections/<peer>/<volume>.
+func readDRBD9DebugFS(root string) (*drbd9Stats, error) {
+	files, err := filepath.Glob(filepath.Join(root, "*", "connections", "*", "*", "proc_drbd"))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := newDRBD9Stats()
+	for _, file := range files {
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return nil, err
+		}
+		// <resource>/connections/<peer>/<volume>/proc_drbd
+		parts := strings.Split(rel, string(filepath.Separator))
+		resource, peer, volume := parts[0], parts[2], parts[3]
+
+		f, err := os.Open(file)
+		if err != nil {
+			// The peer or volume may have been removed in the meantime.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		err = stats.parseProcDRBD(f, resource, peer, volume)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %s: %s", file, err)
+		}
+	}
+	return stats, nil
+}
+
+// parseProcDRBD parses the /proc/drbd like statistics of a volume as seen by
+// a single peer.
+func (s *drbd9Stats) parseProcDRBD(r io.Reader, resource, peer, volume string) error {
+	device := s.device(resource, volume)
+	peerDevice := device.peerDevice(peer)
+	connection := s.connection(resource, peer)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanWords)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		if _, err := strconv.ParseUint(kv[0], 10, 64); err == nil && kv[1] == "" {
+			device.minor = kv[0]
+			continue
+		}
+
+		switch kv[0] {
+		case "cs":
+			connection.state = kv[1]
+			if drbd9ReplicationStates[kv[1]] {
+				connection.state = "Connected"
+			}
+			continue
+		case "ro", "ds":
+			values := strings.Split(kv[1], "/")
+			if len(values) != 2 {
+				return fmt.Errorf("invalid %s value %q", kv[0], kv[1])
+			}
+			if kv[0] == "ro" {
+				s.roles[resource] = values[0]
+				connection.peerRole = values[1]
+			} else {
+				device.disk = values[0]
+				peerDevice.peerDisk = values[1]
+			}
+			continue
+		}
+
+		var dst *float64
+		switch kv[0] {
+		case "ns":
+			dst = &peerDevice.sent
+		case "nr":
+			dst = &peerDevice.received
+		case "pe":
+			dst = &peerDevice.pending
+		case "ua":
+			dst = &peerDevice.unacked
+		case "oos":
+			dst = &peerDevice.outOfSync
+		case "dw":
+			dst = &device.written
+		case "dr":
+			dst = &device.read
+		case "al":
+			dst = &device.alWrites
+		case "bm":
+			dst = &device.bmWrites
+		case "lo":
+			dst = &device.lowerPending
+		case "ap":
+			dst = &device.upperPending
+		default:
+			continue
+		}
+		value, err := parseDRBD9Value(kv[1])
+		if err != nil {
+			return fmt.Errorf("invalid %s value %q: %s", kv[0], kv[1], err)
+		}
+		*dst = value
+	}
+	return scanner.Err()
+}
+
+// parseDRBD9Value parses numbers, and the pending request counts DRBD 9
+// splits by direction, e.g. "[0;2]", into their sum.
+func parseDRBD9Value(s string) (float64, error) {
+	if !strings.HasPrefix(s, "[") {
+		return strconv.ParseFloat(s, 64)
+	}
+	var sum float64
+	for _, v := range strings.Split(strings.Trim(s, "[]"), ";") {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, err
+		}
+		sum += f
+	}
+	return sum, nil
+}
+
+// parseDRBD9Events2 parses the output of `drbdsetup events2 --now --statistics`.
+func parseDRBD9Events2(r io.Reader) (*drbd9Stats, error) {
+	stats := newDRBD9Stats()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Events are "<event> <object> key:value...", the initial state
+		// ends with "exists -".
+		if len(fields) < 3 {
+			continue
+		}
+		values := map[string]string{}
+		for _, field := range fields[2:] {
+			if kv := strings.SplitN(field, ":", 2); len(kv) == 2 {
+				values[kv[0]] = kv[1]
+			}
+		}
+
+		resource := values["name"]
+		switch fields[1] {
+		case "resource":
+			stats.roles[resource] = values["role"]
+		case "connection":
+			connection := stats.connection(resource, values["conn-name"])
+			connection.state = values["connection"]
+			connection.peerRole = values["role"]
+		case "device":
+			device := stats.device(resource, values["volume"])
+			device.minor = values["minor"]
+			device.disk = values["disk"]
+			for key, dst := range map[string]*float64{
+				"read":          &device.read,
+				"written":       &device.written,
+				"al-writes":     &device.alWrites,
+				"bm-writes":     &device.bmWrites,
+				"lower-pending": &device.lowerPending,
+				"upper-pending": &device.upperPending,
+			} {
+				if err := parseDRBD9Events2Value(values, key, dst); err != nil {
+					return nil, err
+				}
+			}
+		case "peer-device":
+			peerDevice := stats.device(resource, values["volume"]).peerDevice(values["conn-name"])
+			peerDevice.peerDisk = values["peer-disk"]
+			for key, dst := range map[string]*float64{
+				"sent":        &peerDevice.sent,
+				"received":    &peerDevice.received,
+				"out-of-sync": &peerDevice.outOfSync,
+				"pending":     &peerDevice.pending,
+				"unacked":     &peerDevice.unacked,
+			} {
+				if err := parseDRBD9Events2Value(values, key, dst); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return stats, scanner.Err()
+}
+
+func parseDRBD9Events2Value(values map[string]string, key string, dst *float64) error {
+	v, ok := values[key]
+	if !ok {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s value %q: %s", key, v, err)
+	}
+	*dst = f
+	return nil
+}
+
+func (s *drbd9Stats) update(ch chan<- prometheus.Metric) {
+	for resource, role := range s.roles {
+		ch <- prometheus.MustNewConstMetric(drbd9RoleIsPrimary, prometheus.GaugeValue,
+			drbd9IsOkay(role, "Primary"), resource, "local")
+	}
+	for _, c := range s.connections {
+		ch <- prometheus.MustNewConstMetric(drbd9Connected, prometheus.GaugeValue,
+			drbd9IsOkay(c.state, "Connected"), c.resource, c.peer)
+		if c.peerRole != "" {
+			ch <- prometheus.MustNewConstMetric(drbd9RoleIsPrimary, prometheus.GaugeValue,
+				drbd9IsOkay(c.peerRole, "Primary"), c.resource, c.peer)
+		}
+	}
+
+	for _, d := range s.devices {
+		device := "drbd" + d.minor
+		labels := []string{device, d.resource, d.volume}
+		ch <- prometheus.MustNewConstMetric(drbd9DiskWritten, prometheus.CounterValue, d.written*1024, labels...)
+		ch <- prometheus.MustNewConstMetric(drbd9DiskRead, prometheus.CounterValue, d.read*1024, labels...)
+		ch <- prometheus.MustNewConstMetric(drbd9ActivityLogWrites, prometheus.CounterValue, d.alWrites, labels...)
+		ch <- prometheus.MustNewConstMetric(drbd9BitmapWrites, prometheus.CounterValue, d.bmWrites, labels...)
+		ch <- prometheus.MustNewConstMetric(drbd9LocalPending, prometheus.GaugeValue, d.lowerPending, labels...)
+		ch <- prometheus.MustNewConstMetric(drbd9ApplicationPending, prometheus.GaugeValue, d.upperPending, labels...)
+		ch <- prometheus.MustNewConstMetric(drbd9DiskUpToDate, prometheus.GaugeValue,
+			drbd9IsOkay(d.disk, "UpToDate"), device, d.resource, d.volume, "local")
+
+		for _, p := range d.peerDevices {
+			labels := []string{device, d.resource, d.volume, p.peer}
+			ch <- prometheus.MustNewConstMetric(drbd9NetworkSent, prometheus.CounterValue, p.sent*1024, labels...)
+			ch <- prometheus.MustNewConstMetric(drbd9NetworkReceived, prometheus.CounterValue, p.received*1024, labels...)
+			ch <- prometheus.MustNewConstMetric(drbd9RemotePending, prometheus.GaugeValue, p.pending, labels...)
+			ch <- prometheus.MustNewConstMetric(drbd9RemoteUnacknowledged, prometheus.GaugeValue, p.unacked, labels...)
+			ch <- prometheus.MustNewConstMetric(drbd9OutOfSync, prometheus.GaugeValue, p.outOfSync*1024, labels...)
+			ch <- prometheus.MustNewConstMetric(drbd9DiskUpToDate, prometheus.GaugeValue,
+				drbd9IsOkay(p.peerDisk, "UpToDate"), device, d.resource, d.volume, p.peer)
+		}
+	}
+}
+
+func drbd9IsOkay(value, okay string) float64 {
+	if value == okay {
+		return 1
+	}
+	return 0
+}
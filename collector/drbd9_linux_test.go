@@ -0,0 +1,68 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"testing"
+)
+
+func TestDRBD9(t *testing.T) {
+	debugFS, err := readDRBD9DebugFS("fixtures/drbd/debug/drbd/resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open("fixtures/drbd/events2.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	events2, err := parseDRBD9Events2(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both formats describe the same state.
+	for name, stats := range map[string]*drbd9Stats{"debugfs": debugFS, "events2": events2} {
+		if stats.roles["r0"] != "Primary" || stats.roles["r1"] != "Secondary" {
+			t.Errorf("%s: unexpected roles %v", name, stats.roles)
+		}
+		if c := stats.connectionIndex["r0/node-c"]; c == nil || c.state != "Connected" || c.peerRole != "Secondary" {
+			t.Errorf("%s: unexpected connection r0/node-c %+v", name, c)
+		}
+		if c := stats.connectionIndex["r1/node-b"]; c == nil || c.state != "Connecting" {
+			t.Errorf("%s: unexpected connection r1/node-b %+v", name, c)
+		}
+		if len(stats.devices) != 3 {
+			t.Fatalf("%s: want 3 devices, got %d", name, len(stats.devices))
+		}
+
+		d := stats.deviceIndex["r0/0"]
+		if d == nil || d.minor != "0" || d.disk != "UpToDate" || d.written != 1052672 || d.alWrites != 17 || d.upperPending != 2 {
+			t.Fatalf("%s: unexpected device r0/0 %+v", name, d)
+		}
+		if len(d.peerDevices) != 2 {
+			t.Fatalf("%s: want 2 peers of r0/0, got %d", name, len(d.peerDevices))
+		}
+		p := d.peerDeviceIndex["node-c"]
+		if p == nil || p.peerDisk != "Inconsistent" || p.sent != 786432 || p.outOfSync != 262144 || p.pending != 5 {
+			t.Errorf("%s: unexpected peer device r0/0/node-c %+v", name, p)
+		}
+
+		if d := stats.deviceIndex["r1/0"]; d == nil || d.minor != "10" || d.bmWrites != 3 || d.peerDeviceIndex["node-b"].received != 65536 {
+			t.Errorf("%s: unexpected device r1/0 %+v", name, d)
+		}
+	}
+}
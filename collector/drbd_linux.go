@@ -22,6 +22,11 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	drbdEvents2File = kingpin.Flag("collector.drbd.events2-file", "File with the output of `drbdsetup events2 --now --statistics` to read DRBD 9 statistics from instead of debugfs.").Default("").String()
 )
 
 // Numerical metric provided by /proc/drbd.
@@ -156,6 +161,33 @@ func newDRBDCollector() (Collector, error) {
 }
 
 func (c *drbdCollector) Update(ch chan<- prometheus.Metric) error {
+	if *drbdEvents2File != "" {
+		file, err := os.Open(*drbdEvents2File)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		stats, err := parseDRBD9Events2(file)
+		if err != nil {
+			return fmt.Errorf("couldn't parse %s: %s", *drbdEvents2File, err)
+		}
+		stats.update(ch)
+		return nil
+	}
+
+	debugFSRoot := sysFilePath("kernel/debug/drbd/resources")
+	if _, err := os.Stat(debugFSRoot); err == nil {
+		stats, err := readDRBD9DebugFS(debugFSRoot)
+		if err != nil {
+			return err
+		}
+		stats.update(ch)
+		return nil
+	} else if !os.IsNotExist(err) {
+		log.Debugf("Not collecting DRBD 9 statistics from %s: %s", debugFSRoot, err)
+	}
+
 	statsFile := procFilePath("drbd")
 	file, err := os.Open(statsFile)
 	if err != nil {
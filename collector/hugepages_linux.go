@@ -0,0 +1,197 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nohugepages
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	hugepagesSubsystem = "hugepages"
+)
+
+var (
+	hugepagesSizeRE = regexp.MustCompile(`hugepages-(\d+)kB$`)
+	thpSettingRE    = regexp.MustCompile(`\[(.+)\]`)
+)
+
+type hugepagesCollector struct {
+	pages, free, reserved, surplus, overcommit typedDesc
+	nodePages, nodeFree, nodeSurplus           typedDesc
+	thpInfo                                    typedDesc
+	khugepagedPagesToScan, khugepagedCollapsed typedDesc
+	khugepagedFullScans, khugepagedSleep       typedDesc
+}
+
+func init() {
+	registerCollector("hugepages", defaultDisabled, NewHugepagesCollector)
+}
+
+// NewHugepagesCollector returns a new Collector exposing hugetlb pages by
+// size and transparent hugepage settings.
+func NewHugepagesCollector() (Collector, error) {
+	desc := func(name, help string, t prometheus.ValueType, labels ...string) typedDesc {
+		return typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, hugepagesSubsystem, name),
+			help, labels, nil,
+		), t}
+	}
+	return &hugepagesCollector{
+		pages:       desc("pages", "Number of hugetlb pages in the pool.", prometheus.GaugeValue, "size"),
+		free:        desc("free_pages", "Number of hugetlb pages in the pool that are not allocated.", prometheus.GaugeValue, "size"),
+		reserved:    desc("reserved_pages", "Number of hugetlb pages reserved for allocation but not yet allocated.", prometheus.GaugeValue, "size"),
+		surplus:     desc("surplus_pages", "Number of hugetlb pages in the pool above nr_hugepages.", prometheus.GaugeValue, "size"),
+		overcommit:  desc("overcommit_pages", "Maximum number of surplus hugetlb pages.", prometheus.GaugeValue, "size"),
+		nodePages:   desc("node_pages", "Number of hugetlb pages in the pool of the NUMA node.", prometheus.GaugeValue, "node", "size"),
+		nodeFree:    desc("node_free_pages", "Number of hugetlb pages in the pool of the NUMA node that are not allocated.", prometheus.GaugeValue, "node", "size"),
+		nodeSurplus: desc("node_surplus_pages", "Number of surplus hugetlb pages of the NUMA node.", prometheus.GaugeValue, "node", "size"),
+		thpInfo: desc("transparent_info", "Transparent hugepage settings, with the value of the enabled and defrag settings as labels.",
+			prometheus.GaugeValue, "enabled", "defrag"),
+		khugepagedPagesToScan: desc("khugepaged_pages_to_scan", "Number of pages khugepaged scans at each pass.", prometheus.GaugeValue),
+		khugepagedCollapsed:   desc("khugepaged_pages_collapsed_total", "Number of huge pages khugepaged collapsed.", prometheus.CounterValue),
+		khugepagedFullScans:   desc("khugepaged_full_scans_total", "Number of times khugepaged scanned all memory.", prometheus.CounterValue),
+		khugepagedSleep:       desc("khugepaged_scan_sleep_seconds", "Time khugepaged sleeps between passes.", prometheus.GaugeValue),
+	}, nil
+}
+
+func (c *hugepagesCollector) Update(ch chan<- prometheus.Metric) error {
+	sizes, err := filepath.Glob(sysFilePath("kernel/mm/hugepages/hugepages-*kB"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range sizes {
+		size, err := hugepagesSize(dir)
+		if err != nil {
+			return err
+		}
+		for _, m := range []struct {
+			file string
+			desc typedDesc
+		}{
+			{"nr_hugepages", c.pages},
+			{"free_hugepages", c.free},
+			{"resv_hugepages", c.reserved},
+			{"surplus_hugepages", c.surplus},
+			{"nr_overcommit_hugepages", c.overcommit},
+		} {
+			v, err := readUintFromFile(filepath.Join(dir, m.file))
+			if err != nil {
+				return fmt.Errorf("couldn't get %s of %s hugepages: %s", m.file, size, err)
+			}
+			ch <- m.desc.mustNewConstMetric(float64(v), size)
+		}
+	}
+
+	nodeSizes, err := filepath.Glob(sysFilePath("devices/system/node/node[0-9]*/hugepages/hugepages-*kB"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range nodeSizes {
+		size, err := hugepagesSize(dir)
+		if err != nil {
+			return err
+		}
+		node := strings.TrimPrefix(filepath.Base(filepath.Dir(filepath.Dir(dir))), "node")
+		for _, m := range []struct {
+			file string
+			desc typedDesc
+		}{
+			{"nr_hugepages", c.nodePages},
+			{"free_hugepages", c.nodeFree},
+			{"surplus_hugepages", c.nodeSurplus},
+		} {
+			v, err := readUintFromFile(filepath.Join(dir, m.file))
+			if err != nil {
+				return fmt.Errorf("couldn't get %s of %s hugepages of node %s: %s", m.file, size, node, err)
+			}
+			ch <- m.desc.mustNewConstMetric(float64(v), node, size)
+		}
+	}
+
+	return c.updateTransparent(ch)
+}
+
+func (c *hugepagesCollector) updateTransparent(ch chan<- prometheus.Metric) error {
+	thpPath := sysFilePath("kernel/mm/transparent_hugepage")
+	enabled, err := readTHPSetting(filepath.Join(thpPath, "enabled"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Debugf("hugepages collector: transparent hugepages are not supported: %s", err)
+			return nil
+		}
+		return err
+	}
+	defrag, err := readTHPSetting(filepath.Join(thpPath, "defrag"))
+	if err != nil {
+		return err
+	}
+	ch <- c.thpInfo.mustNewConstMetric(1, enabled, defrag)
+
+	for _, m := range []struct {
+		file  string
+		desc  typedDesc
+		scale float64
+	}{
+		{"pages_to_scan", c.khugepagedPagesToScan, 1},
+		{"pages_collapsed", c.khugepagedCollapsed, 1},
+		{"full_scans", c.khugepagedFullScans, 1},
+		{"scan_sleep_millisecs", c.khugepagedSleep, 1000},
+	} {
+		v, err := readUintFromFile(filepath.Join(thpPath, "khugepaged", m.file))
+		if err != nil {
+			return fmt.Errorf("couldn't get khugepaged %s: %s", m.file, err)
+		}
+		ch <- m.desc.mustNewConstMetric(float64(v) / m.scale)
+	}
+	return nil
+}
+
+// hugepagesSize returns the page size in bytes of a hugepages-<size>kB
+// directory.
+func hugepagesSize(dir string) (string, error) {
+	m := hugepagesSizeRE.FindStringSubmatch(dir)
+	if m == nil {
+		return "", fmt.Errorf("invalid hugepages directory: %s", dir)
+	}
+	kb, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatUint(kb*1024, 10), nil
+}
+
+// readTHPSetting returns the selected value of a transparent hugepage
+// setting, which lists all values with the selected one in brackets.
+func readTHPSetting(file string) (string, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	m := thpSettingRE.FindStringSubmatch(string(content))
+	if m == nil {
+		return "", fmt.Errorf("no setting selected in %s: %q", file, strings.TrimSpace(string(content)))
+	}
+	return m[1], nil
+}
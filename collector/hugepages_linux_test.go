@@ -0,0 +1,54 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nohugepages
+
+package collector
+
+import (
+	"testing"
+)
+
+func TestHugepagesSize(t *testing.T) {
+	for dir, want := range map[string]string{
+		"fixtures/sys/kernel/mm/hugepages/hugepages-2048kB":                    "2097152",
+		"fixtures/sys/devices/system/node/node1/hugepages/hugepages-1048576kB": "1073741824",
+	} {
+		got, err := hugepagesSize(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want != got {
+			t.Errorf("%s: want size %s, got %s", dir, want, got)
+		}
+	}
+
+	if _, err := hugepagesSize("fixtures/sys/kernel/mm/hugepages"); err == nil {
+		t.Error("want error for invalid directory")
+	}
+}
+
+func TestTHPSetting(t *testing.T) {
+	for file, want := range map[string]string{
+		"fixtures/sys/kernel/mm/transparent_hugepage/enabled": "madvise",
+		"fixtures/sys/kernel/mm/transparent_hugepage/defrag":  "madvise",
+	} {
+		got, err := readTHPSetting(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want != got {
+			t.Errorf("%s: want setting %s, got %s", file, want, got)
+		}
+	}
+}
@@ -18,8 +18,11 @@ package collector
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -37,6 +40,8 @@ type infinibandCollector struct {
 	metricDescs    map[string]*prometheus.Desc
 	counters       map[string]infinibandMetric
 	legacyCounters map[string]infinibandMetric
+
+	info, stateID, physicalStateID, rate, hwCounter typedDesc
 }
 
 type infinibandMetric struct {
@@ -97,6 +102,39 @@ func NewInfiniBandCollector() (Collector, error) {
 		)
 	}
 
+	i.info = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "info"),
+		"Non-numeric data from /sys/class/infiniband/<device>, value is always 1.",
+		[]string{"device", "board_id", "firmware_version", "hca_type"},
+		nil,
+	), prometheus.GaugeValue}
+	i.stateID = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "state_id"),
+		"State of the InfiniBand port (0: no change, 1: down, 2: init, 3: armed, 4: active, 5: act defer).",
+		[]string{"device", "port"},
+		nil,
+	), prometheus.GaugeValue}
+	i.physicalStateID = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "physical_state_id"),
+		"Physical state of the InfiniBand port (0: no change, 1: sleep, 2: polling, 3: disable, 4: shift, 5: link up, 6: link error recover, 7: phytest).",
+		[]string{"device", "port"},
+		nil,
+	), prometheus.GaugeValue}
+	i.rate = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "rate_bytes_per_second"),
+		"Maximum signal transfer rate of the InfiniBand port.",
+		[]string{"device", "port"},
+		nil,
+	), prometheus.GaugeValue}
+	// The hw_counters files are driver specific and hold both counters and
+	// gauges, such as active_qps of bnxt_re.
+	i.hwCounter = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "hw_counter"),
+		"Value of a driver specific InfiniBand hardware counter of the port.",
+		[]string{"device", "port", "counter"},
+		nil,
+	), prometheus.UntypedValue}
+
 	return &i, nil
 }
 
@@ -170,6 +208,40 @@ func readMetric(directory, metricFile string) (uint64, error) {
 	return metric, nil
 }
 
+// readInfinibandString returns the trimmed content of an InfiniBand sysfs
+// file, or "" if the driver doesn't provide it.
+func readInfinibandString(file string) string {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// parseInfinibandStateID parses port state files like "4: ACTIVE".
+func parseInfinibandStateID(state string) (uint64, error) {
+	parts := strings.SplitN(state, ":", 2)
+	id, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port state %q: %s", state, err)
+	}
+	return id, nil
+}
+
+// parseInfinibandRate parses port rate files like "40 Gb/sec (4X QDR)" into
+// bytes per second.
+func parseInfinibandRate(rate string) (float64, error) {
+	fields := strings.Fields(rate)
+	if len(fields) < 2 || fields[1] != "Gb/sec" {
+		return 0, fmt.Errorf("invalid port rate %q", rate)
+	}
+	gbits, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port rate %q: %s", rate, err)
+	}
+	return gbits * 1000 * 1000 * 1000 / 8, nil
+}
+
 func (c *infinibandCollector) Update(ch chan<- prometheus.Metric) error {
 	devices, err := infinibandDevices(sysFilePath(infinibandPath))
 
@@ -184,6 +256,14 @@ func (c *infinibandCollector) Update(ch chan<- prometheus.Metric) error {
 	}
 
 	for _, device := range devices {
+		deviceFiles := sysFilePath(filepath.Join(infinibandPath, device))
+		ch <- c.info.mustNewConstMetric(1,
+			device,
+			readInfinibandString(filepath.Join(deviceFiles, "board_id")),
+			readInfinibandString(filepath.Join(deviceFiles, "fw_ver")),
+			readInfinibandString(filepath.Join(deviceFiles, "hca_type")),
+		)
+
 		ports, err := infinibandPorts(sysFilePath(infinibandPath), device)
 
 		// If no ports are found for the specified device, skip to the next device.
@@ -198,12 +278,27 @@ func (c *infinibandCollector) Update(ch chan<- prometheus.Metric) error {
 		for _, port := range ports {
 			portFiles := sysFilePath(filepath.Join(infinibandPath, device, "ports", port))
 
+			if err := c.updatePortState(ch, portFiles, device, port); err != nil {
+				return err
+			}
+
 			// Add metrics for the InfiniBand counters.
 			for metricName, infinibandMetric := range c.counters {
-				if _, err := os.Stat(filepath.Join(portFiles, "counters", infinibandMetric.File)); os.IsNotExist(err) {
+				counterDir, counterFile := filepath.Join(portFiles, "counters"), infinibandMetric.File
+				// The port data counters are only 32 bits wide and wrap within
+				// seconds on fast links, mlx5 devices provide 64 bit versions.
+				if strings.HasPrefix(device, "mlx5") {
+					switch counterFile {
+					case "port_rcv_data", "port_xmit_data":
+						if _, err := os.Stat(filepath.Join(portFiles, "counters_ext", counterFile+"_64")); err == nil {
+							counterDir, counterFile = filepath.Join(portFiles, "counters_ext"), counterFile+"_64"
+						}
+					}
+				}
+				if _, err := os.Stat(filepath.Join(counterDir, counterFile)); os.IsNotExist(err) {
 					continue
 				}
-				metric, err := readMetric(filepath.Join(portFiles, "counters"), infinibandMetric.File)
+				metric, err := readMetric(counterDir, counterFile)
 				if err != nil {
 					return err
 				}
@@ -235,8 +330,63 @@ func (c *infinibandCollector) Update(ch chan<- prometheus.Metric) error {
 					port,
 				)
 			}
+
+			if err := c.updateHWCounters(ch, filepath.Join(portFiles, "hw_counters"), device, port); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
+
+func (c *infinibandCollector) updatePortState(ch chan<- prometheus.Metric, portFiles, device, port string) error {
+	if state := readInfinibandString(filepath.Join(portFiles, "state")); state != "" {
+		id, err := parseInfinibandStateID(state)
+		if err != nil {
+			return err
+		}
+		ch <- c.stateID.mustNewConstMetric(float64(id), device, port)
+	}
+	if state := readInfinibandString(filepath.Join(portFiles, "phys_state")); state != "" {
+		id, err := parseInfinibandStateID(state)
+		if err != nil {
+			return err
+		}
+		ch <- c.physicalStateID.mustNewConstMetric(float64(id), device, port)
+	}
+	if rate := readInfinibandString(filepath.Join(portFiles, "rate")); rate != "" {
+		bytes, err := parseInfinibandRate(rate)
+		if err != nil {
+			return err
+		}
+		ch <- c.rate.mustNewConstMetric(bytes, device, port)
+	}
+	return nil
+}
+
+// updateHWCounters exposes the driver specific counters in the hw_counters
+// directory of a port, e.g. out_of_buffer or rnr_nak_retry_err of mlx5.
+func (c *infinibandCollector) updateHWCounters(ch chan<- prometheus.Metric, dir, device, port string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, file := range files {
+		// lifespan is the update interval of the counters in milliseconds.
+		if file.IsDir() || file.Name() == "lifespan" {
+			continue
+		}
+		value, err := readUintFromFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			log.Debugf("infiniband collector: skipping hw counter %s of %s port %s: %s", file.Name(), device, port, err)
+			continue
+		}
+		ch <- c.hwCounter.mustNewConstMetric(float64(value), device, port, file.Name())
+	}
+	return nil
+}
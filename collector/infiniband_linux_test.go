@@ -23,7 +23,7 @@ func TestInfiniBandDevices(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if l := len(devices); l != 2 {
+	if l := len(devices); l != 3 {
 		t.Fatalf("Retrieved an unexpected number of InfiniBand devices: %d", l)
 	}
 }
@@ -38,3 +38,34 @@ func TestInfiniBandPorts(t *testing.T) {
 		t.Fatalf("Retrieved an unexpected number of InfiniBand ports: %d", l)
 	}
 }
+
+func TestInfiniBandPortState(t *testing.T) {
+	for state, want := range map[string]uint64{
+		"4: ACTIVE":   4,
+		"1: DOWN":     1,
+		"5: LinkUp":   5,
+		"3: Disabled": 3,
+	} {
+		got, err := parseInfinibandStateID(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%q: want %d, got %d", state, want, got)
+		}
+	}
+
+	for rate, want := range map[string]float64{
+		"40 Gb/sec (4X QDR)":  5e9,
+		"100 Gb/sec (4X EDR)": 12.5e9,
+		"2.5 Gb/sec (1X SDR)": 312.5e6,
+	} {
+		got, err := parseInfinibandRate(rate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%q: want %f, got %f", rate, want, got)
+		}
+	}
+}
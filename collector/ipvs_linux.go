@@ -16,13 +16,23 @@
 package collector
 
 import (
+	"bufio"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
 	"github.com/prometheus/procfs"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	ipvsFixtures = kingpin.Flag("collector.ipvs.fixtures", "test fixtures to use for ipvs collector netlink metrics").Default("").String()
 )
 
 type ipvsCollector struct {
@@ -30,6 +40,12 @@ type ipvsCollector struct {
 	fs                                                                          procfs.FS
 	backendConnectionsActive, backendConnectionsInact, backendWeight            typedDesc
 	connections, incomingPackets, outgoingPackets, incomingBytes, outgoingBytes typedDesc
+	backendInfo                                                                 typedDesc
+
+	serviceInfo, servicePersistenceTimeout                             typedDesc
+	serviceConnections, serviceIncomingPackets                         typedDesc
+	serviceOutgoingPackets, serviceIncomingBytes, serviceOutgoingBytes typedDesc
+	syncDaemonRunning, syncDaemonInfo                                  typedDesc
 }
 
 func init() {
@@ -51,6 +67,13 @@ func newIPVSCollector() (*ipvsCollector, error) {
 			"remote_port",
 			"proto",
 		}
+		ipvsServiceLabelNames = []string{
+			"local_address",
+			"local_port",
+			"local_mark",
+			"proto",
+			"scheduler",
+		}
 		c         ipvsCollector
 		err       error
 		subsystem = "ipvs"
@@ -102,6 +125,57 @@ func newIPVSCollector() (*ipvsCollector, error) {
 		ipvsBackendLabelNames, nil,
 	), prometheus.GaugeValue}
 
+	c.backendInfo = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "backend_info"),
+		"The packet forwarding method of the backend by local and remote address.",
+		append(ipvsBackendLabelNames, "forwarding_method"), nil,
+	), prometheus.GaugeValue}
+	c.serviceInfo = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "service_info"),
+		"The scheduler and flags of the virtual service.",
+		append(ipvsServiceLabelNames, "flags"), nil,
+	), prometheus.GaugeValue}
+	c.servicePersistenceTimeout = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "service_persistence_timeout_seconds"),
+		"The persistence timeout of the virtual service.",
+		ipvsServiceLabelNames, nil,
+	), prometheus.GaugeValue}
+	c.serviceConnections = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "service_connections_total"),
+		"The total number of connections made to the virtual service.",
+		ipvsServiceLabelNames, nil,
+	), prometheus.CounterValue}
+	c.serviceIncomingPackets = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "service_incoming_packets_total"),
+		"The total number of incoming packets of the virtual service.",
+		ipvsServiceLabelNames, nil,
+	), prometheus.CounterValue}
+	c.serviceOutgoingPackets = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "service_outgoing_packets_total"),
+		"The total number of outgoing packets of the virtual service.",
+		ipvsServiceLabelNames, nil,
+	), prometheus.CounterValue}
+	c.serviceIncomingBytes = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "service_incoming_bytes_total"),
+		"The total amount of incoming data of the virtual service.",
+		ipvsServiceLabelNames, nil,
+	), prometheus.CounterValue}
+	c.serviceOutgoingBytes = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "service_outgoing_bytes_total"),
+		"The total amount of outgoing data of the virtual service.",
+		ipvsServiceLabelNames, nil,
+	), prometheus.CounterValue}
+	c.syncDaemonRunning = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "sync_daemon_running"),
+		"Whether the connection synchronization daemon is running in the given state.",
+		[]string{"state"}, nil,
+	), prometheus.GaugeValue}
+	c.syncDaemonInfo = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "sync_daemon_info"),
+		"The multicast interface and sync ID of a running connection synchronization daemon.",
+		[]string{"state", "interface", "sync_id"}, nil,
+	), prometheus.GaugeValue}
+
 	return &c, nil
 }
 
@@ -125,6 +199,11 @@ func (c *ipvsCollector) Update(ch chan<- prometheus.Metric) error {
 	if err != nil {
 		return fmt.Errorf("could not get backend status: %s", err)
 	}
+	// procfs doesn't parse the forwarding method, read it separately.
+	forwardMethods, err := readIPVSForwardMethods(procFilePath("net/ip_vs"))
+	if err != nil {
+		return fmt.Errorf("could not get backend forwarding methods: %s", err)
+	}
 
 	for _, backend := range backendStats {
 		labelValues := []string{
@@ -137,6 +216,152 @@ func (c *ipvsCollector) Update(ch chan<- prometheus.Metric) error {
 		ch <- c.backendConnectionsActive.mustNewConstMetric(float64(backend.ActiveConn), labelValues...)
 		ch <- c.backendConnectionsInact.mustNewConstMetric(float64(backend.InactConn), labelValues...)
 		ch <- c.backendWeight.mustNewConstMetric(float64(backend.Weight), labelValues...)
+		key := ipvsBackendKey{
+			proto:         backend.Proto,
+			localAddress:  backend.LocalAddress.String(),
+			localPort:     backend.LocalPort,
+			localMark:     backend.LocalMark,
+			remoteAddress: backend.RemoteAddress.String(),
+			remotePort:    backend.RemotePort,
+		}
+		if method, ok := forwardMethods[key]; ok {
+			ch <- c.backendInfo.mustNewConstMetric(1, append(labelValues, method)...)
+		}
+	}
+
+	return c.updateNetlink(ch)
+}
+
+// ipvsBackendKey identifies a backend of a virtual service in /proc/net/ip_vs.
+type ipvsBackendKey struct {
+	proto, localAddress, localMark, remoteAddress string
+	localPort, remotePort                         uint16
+}
+
+// readIPVSForwardMethods reads the packet forwarding method of every backend
+// from /proc/net/ip_vs.
+func readIPVSForwardMethods(path string) (map[ipvsBackendKey]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseIPVSForwardMethods(f)
+}
+
+func parseIPVSForwardMethods(r io.Reader) (map[ipvsBackendKey]string, error) {
+	var (
+		methods = map[ipvsBackendKey]string{}
+		service ipvsBackendKey
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "TCP", "UDP":
+			ip, port, err := parseIPVSAddress(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			service = ipvsBackendKey{proto: fields[0], localAddress: ip.String(), localPort: port}
+		case "FWM":
+			service = ipvsBackendKey{proto: fields[0], localAddress: net.IP(nil).String(), localMark: fields[1]}
+		case "->":
+			// The header line names the columns, "-> RemoteAddress:Port Forward ...".
+			if len(fields) < 3 || fields[1] == "RemoteAddress:Port" {
+				continue
+			}
+			ip, port, err := parseIPVSAddress(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			backend := service
+			backend.remoteAddress, backend.remotePort = ip.String(), port
+			methods[backend] = fields[2]
+		}
+	}
+	return methods, scanner.Err()
+}
+
+// parseIPVSAddress parses the hexadecimal IPv4 or bracketed IPv6 addresses
+// and the hexadecimal port in /proc/net/ip_vs, e.g. C0A80016:0CEA.
+func parseIPVSAddress(s string) (net.IP, uint16, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return nil, 0, fmt.Errorf("invalid address %q", s)
+	}
+	port, err := strconv.ParseUint(s[i+1:], 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid port in address %q: %s", s, err)
+	}
+
+	host := s[:i]
+	var ip net.IP
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		ip = net.ParseIP(host[1 : len(host)-1])
+	} else if b, err := hex.DecodeString(host); err == nil && len(b) == net.IPv4len {
+		ip = net.IP(b)
+	}
+	if ip == nil {
+		return nil, 0, fmt.Errorf("invalid address %q", s)
+	}
+	return ip, uint16(port), nil
+}
+
+// updateNetlink exposes the per-service and sync daemon metrics, which are
+// not available in /proc. Reading them requires CAP_NET_ADMIN.
+func (c *ipvsCollector) updateNetlink(ch chan<- prometheus.Metric) error {
+	nl, err := newIPVSNetlinker(*ipvsFixtures)
+	if err != nil {
+		log.Debugf("ipvs collector: netlink is not available, not collecting service metrics: %s", err)
+		return nil
+	}
+	defer nl.Close()
+
+	services, err := nl.Services()
+	if err != nil {
+		if os.IsPermission(err) {
+			log.Debugf("ipvs collector: not permitted to read services through netlink: %s", err)
+			return nil
+		}
+		return fmt.Errorf("could not get IPVS services: %s", err)
+	}
+	for _, svc := range services {
+		labelValues := []string{
+			svc.LocalAddress,
+			strconv.FormatUint(uint64(svc.LocalPort), 10),
+			svc.LocalMark,
+			svc.Proto,
+			svc.Scheduler,
+		}
+		ch <- c.serviceInfo.mustNewConstMetric(1, append(labelValues, strings.Join(svc.Flags, ","))...)
+		ch <- c.servicePersistenceTimeout.mustNewConstMetric(float64(svc.Timeout), labelValues...)
+		ch <- c.serviceConnections.mustNewConstMetric(float64(svc.Connections), labelValues...)
+		ch <- c.serviceIncomingPackets.mustNewConstMetric(float64(svc.IncomingPackets), labelValues...)
+		ch <- c.serviceOutgoingPackets.mustNewConstMetric(float64(svc.OutgoingPackets), labelValues...)
+		ch <- c.serviceIncomingBytes.mustNewConstMetric(float64(svc.IncomingBytes), labelValues...)
+		ch <- c.serviceOutgoingBytes.mustNewConstMetric(float64(svc.OutgoingBytes), labelValues...)
+	}
+
+	daemons, err := nl.SyncDaemons()
+	if err != nil {
+		return fmt.Errorf("could not get IPVS sync daemons: %s", err)
+	}
+	running := map[string]bool{"master": false, "backup": false}
+	for _, daemon := range daemons {
+		running[daemon.State] = true
+		ch <- c.syncDaemonInfo.mustNewConstMetric(1, daemon.State, daemon.Interface, strconv.FormatUint(uint64(daemon.SyncID), 10))
+	}
+	for state, r := range running {
+		v := 0.0
+		if r {
+			v = 1
+		}
+		ch <- c.syncDaemonRunning.mustNewConstMetric(v, state)
 	}
 	return nil
 }
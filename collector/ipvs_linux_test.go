@@ -14,13 +14,18 @@
 package collector
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -117,3 +122,94 @@ wantLoop:
 		t.Fatalf("Missing expected output line(s), first missing line is %s", want)
 	}
 }
+
+func TestIPVSParseService(t *testing.T) {
+	mustMarshal := func(attrs []netlink.Attribute) []byte {
+		b, err := netlink.MarshalAttributes(attrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	port := make([]byte, 2)
+	binary.BigEndian.PutUint16(port, 3306)
+	addr := make([]byte, 16)
+	copy(addr, net.ParseIP("192.168.0.57").To4())
+
+	svc, err := parseIPVSService(mustMarshal([]netlink.Attribute{
+		{Type: ipvsSvcAttrAF, Data: nlenc.Uint16Bytes(2)},
+		{Type: ipvsSvcAttrProtocol, Data: nlenc.Uint16Bytes(6)},
+		{Type: ipvsSvcAttrAddr, Data: addr},
+		{Type: ipvsSvcAttrPort, Data: port},
+		{Type: ipvsSvcAttrSchedName, Data: nlenc.Bytes("mh")},
+		{Type: ipvsSvcAttrFlags, Data: append(nlenc.Uint32Bytes(0x3), nlenc.Uint32Bytes(0xffffffff)...)},
+		{Type: ipvsSvcAttrTimeout, Data: nlenc.Uint32Bytes(360)},
+		{Type: ipvsSvcAttrStats, Data: mustMarshal([]netlink.Attribute{
+			{Type: ipvsStatsAttrConns, Data: nlenc.Uint32Bytes(1)},
+		})},
+		{Type: ipvsSvcAttrStats64, Data: mustMarshal([]netlink.Attribute{
+			{Type: ipvsStatsAttrConns, Data: nlenc.Uint64Bytes(2997)},
+			{Type: ipvsStatsAttrInPkts, Data: nlenc.Uint64Bytes(184312)},
+			{Type: ipvsStatsAttrInBytes, Data: nlenc.Uint64Bytes(27916844)},
+		})},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := ipvsService{
+		LocalAddress:    "192.168.0.57",
+		LocalPort:       3306,
+		Proto:           "TCP",
+		Scheduler:       "mh",
+		Flags:           []string{"persistent", "hashed"},
+		Timeout:         360,
+		Connections:     2997,
+		IncomingPackets: 184312,
+		IncomingBytes:   27916844,
+	}
+	if !reflect.DeepEqual(svc, want) {
+		t.Errorf("want %+v, got %+v", want, svc)
+	}
+
+	daemon, err := parseIPVSSyncDaemon(mustMarshal([]netlink.Attribute{
+		{Type: ipvsDaemonAttrState, Data: nlenc.Uint32Bytes(ipvsStateBackup)},
+		{Type: ipvsDaemonAttrMcastIfn, Data: nlenc.Bytes("eth1")},
+		{Type: ipvsDaemonAttrSyncID, Data: nlenc.Uint32Bytes(51)},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ipvsSyncDaemon{State: "backup", Interface: "eth1", SyncID: 51}); daemon != want {
+		t.Errorf("want %+v, got %+v", want, daemon)
+	}
+}
+
+func TestIPVSParseForwardMethods(t *testing.T) {
+	const ipvs = `IP Virtual Server version 1.2.1 (size=4096)
+Prot LocalAddress:Port Scheduler Flags
+  -> RemoteAddress:Port Forward Weight ActiveConn InActConn
+TCP  C0A80016:0CEA wlc
+  -> C0A85216:0CEA      Tunnel  100    248        2
+UDP  [2620:0000:0000:0000:0000:0000:0000:0001]:0035 rr
+  -> [2620:0000:0000:0000:0000:0000:0000:0002]:0035      Masq    1      0          0
+FWM  10001000 wlc
+  -> C0A8321A:0CEA      Route   0      0          0
+`
+	methods, err := parseIPVSForwardMethods(strings.NewReader(ipvs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[ipvsBackendKey]string{
+		{proto: "TCP", localAddress: "192.168.0.22", localPort: 3306, remoteAddress: "192.168.82.22", remotePort: 3306}: "Tunnel",
+		{proto: "UDP", localAddress: "2620::1", localPort: 53, remoteAddress: "2620::2", remotePort: 53}:                "Masq",
+		{proto: "FWM", localAddress: "<nil>", localMark: "10001000", remoteAddress: "192.168.50.26", remotePort: 3306}:  "Route",
+	} {
+		if got := methods[key]; got != want {
+			t.Errorf("want forwarding method %q for %+v, got %q", want, key, got)
+		}
+	}
+	if want, got := 3, len(methods); want != got {
+		t.Errorf("want %d backends, got %d", want, got)
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !noipvs
+
+package collector
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+)
+
+// Constants from linux/ip_vs.h.
+const (
+	ipvsGenlName = "IPVS"
+
+	// Strips NLA_F_NESTED and NLA_F_NET_BYTEORDER from attribute types.
+	ipvsNlaTypeMask = 0x3fff
+
+	ipvsCmdGetService = 4
+	ipvsCmdGetDaemon  = 11
+
+	ipvsCmdAttrService = 1
+	ipvsCmdAttrDaemon  = 3
+
+	ipvsSvcAttrAF        = 1
+	ipvsSvcAttrProtocol  = 2
+	ipvsSvcAttrAddr      = 3
+	ipvsSvcAttrPort      = 4
+	ipvsSvcAttrFWMark    = 5
+	ipvsSvcAttrSchedName = 6
+	ipvsSvcAttrFlags     = 7
+	ipvsSvcAttrTimeout   = 8
+	ipvsSvcAttrStats     = 10
+	ipvsSvcAttrStats64   = 12
+
+	ipvsStatsAttrConns    = 1
+	ipvsStatsAttrInPkts   = 2
+	ipvsStatsAttrOutPkts  = 3
+	ipvsStatsAttrInBytes  = 4
+	ipvsStatsAttrOutBytes = 5
+
+	ipvsDaemonAttrState    = 1
+	ipvsDaemonAttrMcastIfn = 2
+	ipvsDaemonAttrSyncID   = 3
+
+	ipvsStateMaster = 1
+	ipvsStateBackup = 2
+)
+
+// ipvsServiceFlags are the names of the IP_VS_SVC_F_* service flags.
+var ipvsServiceFlags = []struct {
+	flag uint32
+	name string
+}{
+	{0x0001, "persistent"},
+	{0x0002, "hashed"},
+	{0x0004, "ops"},
+	{0x0008, "sched1"},
+	{0x0010, "sched2"},
+	{0x0020, "sched3"},
+}
+
+// ipvsService holds the configuration and counters of a virtual service, as
+// only exposed by the IPVS generic netlink family.
+type ipvsService struct {
+	LocalAddress string
+	LocalPort    uint16
+	LocalMark    string
+	Proto        string
+	Scheduler    string
+	Flags        []string
+	// Persistence timeout in seconds.
+	Timeout uint32
+
+	Connections     uint64
+	IncomingPackets uint64
+	OutgoingPackets uint64
+	IncomingBytes   uint64
+	OutgoingBytes   uint64
+}
+
+// ipvsSyncDaemon holds the state of a running connection synchronization
+// daemon.
+type ipvsSyncDaemon struct {
+	State     string
+	Interface string
+	SyncID    uint32
+}
+
+// ipvsNetlinker is an interface used to swap out the netlink client for end
+// to end tests.
+type ipvsNetlinker interface {
+	Services() ([]ipvsService, error)
+	SyncDaemons() ([]ipvsSyncDaemon, error)
+	Close() error
+}
+
+// newIPVSNetlinker determines if mocked test fixtures from files should be
+// used for collecting IPVS service metrics, or if netlink should be used.
+func newIPVSNetlinker(fixtures string) (ipvsNetlinker, error) {
+	if fixtures != "" {
+		return &mockIPVSNetlinker{
+			fixtures: fixtures,
+		}, nil
+	}
+
+	c, err := genetlink.Dial(nil)
+	if err != nil {
+		return nil, err
+	}
+	family, err := c.GetFamily(ipvsGenlName)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	return &ipvsNetlinkClient{
+		c:             c,
+		familyID:      family.ID,
+		familyVersion: family.Version,
+	}, nil
+}
+
+type ipvsNetlinkClient struct {
+	c             *genetlink.Conn
+	familyID      uint16
+	familyVersion uint8
+}
+
+func (c *ipvsNetlinkClient) Close() error {
+	return c.c.Close()
+}
+
+func (c *ipvsNetlinkClient) dump(command uint8) ([]genetlink.Message, error) {
+	req := genetlink.Message{
+		Header: genetlink.Header{
+			Command: command,
+			Version: c.familyVersion,
+		},
+	}
+	return c.c.Execute(req, c.familyID, netlink.HeaderFlagsRequest|netlink.HeaderFlagsDump)
+}
+
+func (c *ipvsNetlinkClient) Services() ([]ipvsService, error) {
+	msgs, err := c.dump(ipvsCmdGetService)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []ipvsService
+	for _, m := range msgs {
+		attrs, err := netlink.UnmarshalAttributes(m.Data)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range attrs {
+			if a.Type&ipvsNlaTypeMask != ipvsCmdAttrService {
+				continue
+			}
+			svc, err := parseIPVSService(a.Data)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, svc)
+		}
+	}
+	return services, nil
+}
+
+func (c *ipvsNetlinkClient) SyncDaemons() ([]ipvsSyncDaemon, error) {
+	msgs, err := c.dump(ipvsCmdGetDaemon)
+	if err != nil {
+		return nil, err
+	}
+
+	var daemons []ipvsSyncDaemon
+	for _, m := range msgs {
+		attrs, err := netlink.UnmarshalAttributes(m.Data)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range attrs {
+			if a.Type&ipvsNlaTypeMask != ipvsCmdAttrDaemon {
+				continue
+			}
+			daemon, err := parseIPVSSyncDaemon(a.Data)
+			if err != nil {
+				return nil, err
+			}
+			daemons = append(daemons, daemon)
+		}
+	}
+	return daemons, nil
+}
+
+// parseIPVSService parses the nested IPVS_CMD_ATTR_SERVICE attributes.
+func parseIPVSService(b []byte) (ipvsService, error) {
+	attrs, err := netlink.UnmarshalAttributes(b)
+	if err != nil {
+		return ipvsService{}, err
+	}
+
+	var (
+		svc      ipvsService
+		af       uint16
+		addr     []byte
+		fwmark   uint32
+		stats    []byte
+		stats64  []byte
+		protocol uint16
+	)
+	for _, a := range attrs {
+		switch a.Type & ipvsNlaTypeMask {
+		case ipvsSvcAttrAF:
+			af = nlenc.Uint16(a.Data)
+		case ipvsSvcAttrProtocol:
+			protocol = nlenc.Uint16(a.Data)
+		case ipvsSvcAttrAddr:
+			addr = a.Data
+		case ipvsSvcAttrPort:
+			svc.LocalPort = binary.BigEndian.Uint16(a.Data)
+		case ipvsSvcAttrFWMark:
+			fwmark = nlenc.Uint32(a.Data)
+		case ipvsSvcAttrSchedName:
+			svc.Scheduler = nlenc.String(a.Data)
+		case ipvsSvcAttrFlags:
+			// struct ip_vs_flags, the flags are followed by a mask.
+			if len(a.Data) < 4 {
+				return ipvsService{}, fmt.Errorf("invalid IPVS service flags of length %d", len(a.Data))
+			}
+			flags := nlenc.Uint32(a.Data[:4])
+			for _, f := range ipvsServiceFlags {
+				if flags&f.flag != 0 {
+					svc.Flags = append(svc.Flags, f.name)
+				}
+			}
+		case ipvsSvcAttrTimeout:
+			svc.Timeout = nlenc.Uint32(a.Data)
+		case ipvsSvcAttrStats:
+			stats = a.Data
+		case ipvsSvcAttrStats64:
+			stats64 = a.Data
+		}
+	}
+
+	if fwmark != 0 {
+		svc.Proto = "FWM"
+		svc.LocalMark = fmt.Sprintf("%d", fwmark)
+	} else {
+		switch protocol {
+		case 6:
+			svc.Proto = "TCP"
+		case 17:
+			svc.Proto = "UDP"
+		case 132:
+			svc.Proto = "SCTP"
+		default:
+			svc.Proto = fmt.Sprintf("%d", protocol)
+		}
+		switch {
+		case af == 2 && len(addr) >= net.IPv4len:
+			svc.LocalAddress = net.IP(addr[:net.IPv4len]).String()
+		case len(addr) >= net.IPv6len:
+			svc.LocalAddress = net.IP(addr[:net.IPv6len]).String()
+		}
+	}
+
+	// Kernels since 4.1 provide 64 bit counters, older ones only the 32 bit
+	// IPVS_SVC_ATTR_STATS.
+	if stats64 != nil {
+		stats = stats64
+	}
+	if stats != nil {
+		if err := parseIPVSStats(stats, &svc); err != nil {
+			return ipvsService{}, err
+		}
+	}
+	return svc, nil
+}
+
+func parseIPVSStats(b []byte, svc *ipvsService) error {
+	attrs, err := netlink.UnmarshalAttributes(b)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range attrs {
+		var v uint64
+		switch len(a.Data) {
+		case 4:
+			v = uint64(nlenc.Uint32(a.Data))
+		case 8:
+			v = nlenc.Uint64(a.Data)
+		default:
+			continue
+		}
+		switch a.Type & ipvsNlaTypeMask {
+		case ipvsStatsAttrConns:
+			svc.Connections = v
+		case ipvsStatsAttrInPkts:
+			svc.IncomingPackets = v
+		case ipvsStatsAttrOutPkts:
+			svc.OutgoingPackets = v
+		case ipvsStatsAttrInBytes:
+			svc.IncomingBytes = v
+		case ipvsStatsAttrOutBytes:
+			svc.OutgoingBytes = v
+		}
+	}
+	return nil
+}
+
+// parseIPVSSyncDaemon parses the nested IPVS_CMD_ATTR_DAEMON attributes.
+func parseIPVSSyncDaemon(b []byte) (ipvsSyncDaemon, error) {
+	attrs, err := netlink.UnmarshalAttributes(b)
+	if err != nil {
+		return ipvsSyncDaemon{}, err
+	}
+
+	var daemon ipvsSyncDaemon
+	for _, a := range attrs {
+		switch a.Type & ipvsNlaTypeMask {
+		case ipvsDaemonAttrState:
+			switch nlenc.Uint32(a.Data) {
+			case ipvsStateMaster:
+				daemon.State = "master"
+			case ipvsStateBackup:
+				daemon.State = "backup"
+			}
+		case ipvsDaemonAttrMcastIfn:
+			daemon.Interface = nlenc.String(a.Data)
+		case ipvsDaemonAttrSyncID:
+			daemon.SyncID = nlenc.Uint32(a.Data)
+		}
+	}
+	return daemon, nil
+}
+
+// All code below this point is used to assist with end-to-end tests for
+// the ipvs collector, since IPVS can't be configured in CI.
+
+var _ ipvsNetlinker = &mockIPVSNetlinker{}
+
+type mockIPVSNetlinker struct {
+	fixtures string
+}
+
+func (n *mockIPVSNetlinker) unmarshalJSONFile(filename string, v interface{}) error {
+	b, err := ioutil.ReadFile(filepath.Join(n.fixtures, filename))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
+
+func (n *mockIPVSNetlinker) Close() error { return nil }
+
+func (n *mockIPVSNetlinker) Services() ([]ipvsService, error) {
+	var services []ipvsService
+	if err := n.unmarshalJSONFile("services.json", &services); err != nil {
+		return nil, err
+	}
+
+	return services, nil
+}
+
+func (n *mockIPVSNetlinker) SyncDaemons() ([]ipvsSyncDaemon, error) {
+	var daemons []ipvsSyncDaemon
+	if err := n.unmarshalJSONFile("sync_daemons.json", &daemons); err != nil {
+		return nil, err
+	}
+
+	return daemons, nil
+}
@@ -16,19 +16,34 @@
 package collector
 
 import (
+	"bufio"
 	"fmt"
-	"path"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"github.com/prometheus/procfs"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
-	ksmdFiles = []string{"full_scans", "merge_across_nodes", "pages_shared", "pages_sharing",
-		"pages_to_scan", "pages_unshared", "pages_volatile", "run", "sleep_millisecs"}
+	ksmdProcesses = kingpin.Flag("collector.ksmd.processes", "Expose KSM savings of processes from /proc/[pid]/ksm_stat, aggregated by command name.").Default("false").Bool()
 )
 
 type ksmdCollector struct {
-	metricDescs map[string]*prometheus.Desc
+	metricDescs                 map[string]*prometheus.Desc
+	processMergingPages, profit typedDesc
+}
+
+// ksmdProcessStats contains the KSM savings of the processes with the same
+// command name.
+type ksmdProcessStats struct {
+	mergingPages uint64
+	profit       int64
 }
 
 func init() {
@@ -36,11 +51,11 @@ func init() {
 }
 
 func getCanonicalMetricName(filename string) string {
-	switch filename {
-	case "full_scans":
+	switch {
+	case filename == "full_scans", filename == "pages_scanned", filename == "pages_skipped":
 		return filename + "_total"
-	case "sleep_millisecs":
-		return "sleep_seconds"
+	case strings.HasSuffix(filename, "_millisecs"):
+		return strings.TrimSuffix(filename, "_millisecs") + "_seconds"
 	default:
 		return filename
 	}
@@ -49,34 +64,141 @@ func getCanonicalMetricName(filename string) string {
 // NewKsmdCollector returns a new Collector exposing kernel/system statistics.
 func NewKsmdCollector() (Collector, error) {
 	subsystem := "ksmd"
-	descs := make(map[string]*prometheus.Desc)
-
-	for _, n := range ksmdFiles {
-		descs[n] = prometheus.NewDesc(
-			prometheus.BuildFQName(namespace, subsystem, getCanonicalMetricName(n)),
-			fmt.Sprintf("ksmd '%s' file.", n), nil, nil)
-	}
-	return &ksmdCollector{descs}, nil
+	return &ksmdCollector{
+		metricDescs: map[string]*prometheus.Desc{},
+		processMergingPages: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "process_merging_pages"),
+			"Pages of the processes with the command name merged by KSM.",
+			[]string{"comm"}, nil,
+		), prometheus.GaugeValue},
+		profit: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "process_profit_bytes"),
+			"Memory saved by KSM for the processes with the command name, net of the KSM metadata.",
+			[]string{"comm"}, nil,
+		), prometheus.GaugeValue},
+	}, nil
 }
 
 // Update implements Collector and exposes kernel and system statistics.
 func (c *ksmdCollector) Update(ch chan<- prometheus.Metric) error {
-	for _, n := range ksmdFiles {
-		val, err := readUintFromFile(sysFilePath(path.Join("kernel/mm/ksm", n)))
+	// The files depend on the kernel version, expose all numeric ones.
+	files, err := ioutil.ReadDir(sysFilePath("kernel/mm/ksm"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		n := f.Name()
+		content, err := ioutil.ReadFile(sysFilePath(filepath.Join("kernel/mm/ksm", n)))
 		if err != nil {
 			return err
 		}
+		// general_profit can be negative.
+		val, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+		if err != nil {
+			log.Debugf("ksmd collector: ignoring non-numeric file %s", n)
+			continue
+		}
+
+		desc, ok := c.metricDescs[n]
+		if !ok {
+			desc = prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "ksmd", getCanonicalMetricName(n)),
+				fmt.Sprintf("ksmd '%s' file.", n), nil, nil)
+			c.metricDescs[n] = desc
+		}
 
 		t := prometheus.GaugeValue
 		v := float64(val)
-		switch n {
-		case "full_scans":
+		switch {
+		case strings.HasSuffix(getCanonicalMetricName(n), "_total"):
 			t = prometheus.CounterValue
-		case "sleep_millisecs":
+		case strings.HasSuffix(n, "_millisecs"):
 			v /= 1000
 		}
-		ch <- prometheus.MustNewConstMetric(c.metricDescs[n], t, v)
+		ch <- prometheus.MustNewConstMetric(desc, t, v)
 	}
 
+	if !*ksmdProcesses {
+		return nil
+	}
+	stats, err := getKsmdProcessStats()
+	if err != nil {
+		return fmt.Errorf("couldn't get KSM stats of processes: %s", err)
+	}
+	for comm, s := range stats {
+		ch <- c.processMergingPages.mustNewConstMetric(float64(s.mergingPages), comm)
+		ch <- c.profit.mustNewConstMetric(float64(s.profit), comm)
+	}
 	return nil
 }
+
+// getKsmdProcessStats aggregates the KSM savings of the processes by command
+// name. Processes without merged pages are left out.
+func getKsmdProcessStats() (map[string]*ksmdProcessStats, error) {
+	fs, err := procfs.NewFS(*procPath)
+	if err != nil {
+		return nil, err
+	}
+	procs, err := fs.AllProcs()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]*ksmdProcessStats{}
+	for _, p := range procs {
+		// Processes can vanish at any time and ksm_stat is only there on
+		// kernels 6.1 and later.
+		s, err := readKsmdProcessStat(procFilePath(filepath.Join(strconv.Itoa(p.PID), "ksm_stat")))
+		if err != nil {
+			log.Debugf("couldn't read ksm_stat of pid %d: %s", p.PID, err)
+			continue
+		}
+		if s.mergingPages == 0 && s.profit == 0 {
+			continue
+		}
+		stat, err := p.NewStat()
+		if err != nil {
+			log.Debugf("couldn't read stat of pid %d: %s", p.PID, err)
+			continue
+		}
+		agg, ok := stats[stat.Comm]
+		if !ok {
+			agg = &ksmdProcessStats{}
+			stats[stat.Comm] = agg
+		}
+		agg.mergingPages += s.mergingPages
+		agg.profit += s.profit
+	}
+	return stats, nil
+}
+
+func readKsmdProcessStat(file string) (*ksmdProcessStats, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &ksmdProcessStats{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) != 2 {
+			continue
+		}
+		switch parts[0] {
+		case "ksm_merging_pages":
+			if s.mergingPages, err = strconv.ParseUint(parts[1], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid value in ksm_stat: %s", err)
+			}
+		case "ksm_process_profit":
+			if s.profit, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid value in ksm_stat: %s", err)
+			}
+		}
+	}
+	return s, scanner.Err()
+}
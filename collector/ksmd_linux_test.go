@@ -0,0 +1,44 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !noksmd
+
+package collector
+
+import (
+	"testing"
+)
+
+func TestKsmdCanonicalMetricName(t *testing.T) {
+	for file, want := range map[string]string{
+		"full_scans":                         "full_scans_total",
+		"pages_skipped":                      "pages_skipped_total",
+		"sleep_millisecs":                    "sleep_seconds",
+		"stable_node_chains_prune_millisecs": "stable_node_chains_prune_seconds",
+		"general_profit":                     "general_profit",
+	} {
+		if got := getCanonicalMetricName(file); want != got {
+			t.Errorf("%s: want metric name %s, got %s", file, want, got)
+		}
+	}
+}
+
+func TestKsmdProcessStat(t *testing.T) {
+	s, err := readKsmdProcessStat("fixtures/proc/26231/ksm_stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.mergingPages != 120 || s.profit != 442368 {
+		t.Errorf("want 120 merging pages and 442368 profit, got %d and %d", s.mergingPages, s.profit)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -44,6 +45,31 @@ type mdStatus struct {
 	blocksSynced int64
 }
 
+// mdSyncActions are the values of /sys/block/md*/md/sync_action.
+var mdSyncActions = []string{"idle", "resync", "recover", "check", "repair", "reshape", "frozen"}
+
+// mdMemberStates are the member flags of /sys/block/md*/md/dev-*/state
+// exposed by the collector.
+var mdMemberStates = []string{"in_sync", "faulty", "spare", "write_mostly"}
+
+// mdSysfsStatus holds the array details only found in sysfs.
+type mdSysfsStatus struct {
+	name      string
+	level     string
+	chunkSize uint64
+	// Empty for arrays without redundancy, which have no sync_action,
+	// mismatch_cnt and degraded attributes.
+	syncAction  string
+	mismatchCnt uint64
+	degraded    uint64
+	members     []mdMember
+}
+
+type mdMember struct {
+	name   string
+	states map[string]bool
+}
+
 type mdadmCollector struct{}
 
 func init() {
@@ -218,6 +244,83 @@ func parseMdstat(mdStatusFilePath string) ([]mdStatus, error) {
 	return mdStates, nil
 }
 
+// parseMdSysfs reads the details of all md arrays found below the block
+// directory of sysfs.
+func parseMdSysfs(blockPath string) ([]mdSysfsStatus, error) {
+	dirs, err := filepath.Glob(filepath.Join(blockPath, "md*", "md"))
+	if err != nil {
+		return nil, err
+	}
+
+	var arrays []mdSysfsStatus
+	for _, dir := range dirs {
+		md, err := parseMdSysfsArray(dir)
+		if os.IsNotExist(err) {
+			// The array was stopped while reading it.
+			log.Debugf("Not collecting md array %s: %s", md.name, err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		arrays = append(arrays, md)
+	}
+	return arrays, nil
+}
+
+func parseMdSysfsArray(dir string) (mdSysfsStatus, error) {
+	md := mdSysfsStatus{name: filepath.Base(filepath.Dir(dir))}
+
+	level, err := ioutil.ReadFile(filepath.Join(dir, "level"))
+	if err != nil {
+		return md, err
+	}
+	md.level = strings.TrimSpace(string(level))
+
+	if md.chunkSize, err = readUintFromFile(filepath.Join(dir, "chunk_size")); err != nil && !os.IsNotExist(err) {
+		return md, err
+	}
+
+	syncAction, err := ioutil.ReadFile(filepath.Join(dir, "sync_action"))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return md, err
+	default:
+		md.syncAction = strings.TrimSpace(string(syncAction))
+		if md.mismatchCnt, err = readUintFromFile(filepath.Join(dir, "mismatch_cnt")); err != nil {
+			return md, err
+		}
+		if md.degraded, err = readUintFromFile(filepath.Join(dir, "degraded")); err != nil {
+			return md, err
+		}
+	}
+
+	members, err := filepath.Glob(filepath.Join(dir, "dev-*", "state"))
+	if err != nil {
+		return md, err
+	}
+	for _, member := range members {
+		state, err := ioutil.ReadFile(member)
+		if err != nil {
+			// The member may have been removed in the meantime.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return md, err
+		}
+		m := mdMember{
+			name:   strings.TrimPrefix(filepath.Base(filepath.Dir(member)), "dev-"),
+			states: map[string]bool{},
+		}
+		for _, s := range strings.Split(strings.TrimSpace(string(state)), ",") {
+			m.states[s] = true
+		}
+		md.members = append(md.members, m)
+	}
+	return md, nil
+}
+
 // NewMdadmCollector returns a new Collector exposing raid statistics.
 func NewMdadmCollector() (Collector, error) {
 	return &mdadmCollector{}, nil
@@ -258,6 +361,48 @@ var (
 		[]string{"device"},
 		nil,
 	)
+
+	mdInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "md", "info"),
+		"RAID level of the md-device.",
+		[]string{"device", "level"},
+		nil,
+	)
+
+	chunkSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "md", "chunk_size_bytes"),
+		"Chunk size of the md-device in bytes.",
+		[]string{"device"},
+		nil,
+	)
+
+	syncActionDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "md", "sync_action"),
+		"Current sync action of the md-device.",
+		[]string{"device", "action"},
+		nil,
+	)
+
+	mismatchSectorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "md", "mismatch_sectors"),
+		"Number of sectors found out of sync by the last check or repair of the md-device.",
+		[]string{"device"},
+		nil,
+	)
+
+	degradedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "md", "disks_degraded"),
+		"Number of missing disks of the md-device.",
+		[]string{"device"},
+		nil,
+	)
+
+	memberStateDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "md", "member_state"),
+		"State flags of the members of the md-device.",
+		[]string{"device", "member", "state"},
+		nil,
+	)
 )
 
 func (c *mdadmCollector) Update(ch chan<- prometheus.Metric) error {
@@ -310,5 +455,41 @@ func (c *mdadmCollector) Update(ch chan<- prometheus.Metric) error {
 		)
 	}
 
+	return c.updateSysfs(ch)
+}
+
+func (c *mdadmCollector) updateSysfs(ch chan<- prometheus.Metric) error {
+	arrays, err := parseMdSysfs(sysFilePath("block"))
+	if err != nil {
+		return fmt.Errorf("error reading md sysfs attributes: %s", err)
+	}
+
+	for _, md := range arrays {
+		ch <- prometheus.MustNewConstMetric(mdInfoDesc, prometheus.GaugeValue, 1, md.name, md.level)
+		ch <- prometheus.MustNewConstMetric(chunkSizeDesc, prometheus.GaugeValue, float64(md.chunkSize), md.name)
+
+		if md.syncAction != "" {
+			for _, action := range mdSyncActions {
+				var v float64
+				if action == md.syncAction {
+					v = 1
+				}
+				ch <- prometheus.MustNewConstMetric(syncActionDesc, prometheus.GaugeValue, v, md.name, action)
+			}
+			ch <- prometheus.MustNewConstMetric(mismatchSectorsDesc, prometheus.GaugeValue, float64(md.mismatchCnt), md.name)
+			ch <- prometheus.MustNewConstMetric(degradedDesc, prometheus.GaugeValue, float64(md.degraded), md.name)
+		}
+
+		for _, m := range md.members {
+			for _, state := range mdMemberStates {
+				var v float64
+				if m.states[state] {
+					v = 1
+				}
+				ch <- prometheus.MustNewConstMetric(memberStateDesc, prometheus.GaugeValue, v, md.name, m.name, state)
+			}
+		}
+	}
+
 	return nil
 }
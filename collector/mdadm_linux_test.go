@@ -59,3 +59,30 @@ func TestInvalidMdstat(t *testing.T) {
 		t.Fatalf("parsing of invalid reference file did not find any errors")
 	}
 }
+
+func TestMdadmSysfs(t *testing.T) {
+	arrays, err := parseMdSysfs("fixtures/sys/block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 3, len(arrays); want != got {
+		t.Fatalf("want %d arrays, got %d", want, got)
+	}
+
+	for _, md := range arrays {
+		if md.name != "md7" {
+			continue
+		}
+		if md.level != "raid6" || md.chunkSize != 524288 || md.syncAction != "recover" || md.degraded != 1 {
+			t.Errorf("failed parsing md7 correctly: got %+v", md)
+		}
+		if want, got := 5, len(md.members); want != got {
+			t.Fatalf("want %d members of md7, got %d", want, got)
+		}
+		for _, m := range md.members {
+			if m.name == "sdc1" && (!m.states["faulty"] || m.states["in_sync"]) {
+				t.Errorf("want sdc1 to be faulty, got %v", m.states)
+			}
+		}
+	}
+}
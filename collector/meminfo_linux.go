@@ -23,6 +23,8 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/prometheus/common/log"
 )
 
 func (c *meminfoCollector) getMemInfo() (map[string]float64, error) {
@@ -32,7 +34,24 @@ func (c *meminfoCollector) getMemInfo() (map[string]float64, error) {
 	}
 	defer file.Close()
 
-	return parseMemInfo(file)
+	memInfo, err := parseMemInfo(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if *selfCgroup {
+		m, err := readSelfCgroupMemory(sysFilePath("fs/cgroup"), procFilePath("self/cgroup"))
+		if err != nil {
+			// The cgroup metrics are an addition, don't fail the whole collector.
+			log.Errorf("meminfo collector: couldn't get memory of own cgroup: %s", err)
+			return memInfo, nil
+		}
+		memInfo["cgroup_usage_bytes"] = float64(m.usage)
+		if m.limited {
+			memInfo["cgroup_limit_bytes"] = float64(m.limit)
+		}
+	}
+	return memInfo, nil
 }
 
 func parseMemInfo(r io.Reader) (map[string]float64, error) {
@@ -19,21 +19,29 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 const (
 	memInfoNumaSubsystem = "memory_numa"
 )
 
-var meminfoNodeRE = regexp.MustCompile(`.*devices/system/node/node([0-9]*)`)
+var (
+	meminfoNodeRE = regexp.MustCompile(`.*devices/system/node/node([0-9]*)`)
+
+	numaVMStatFields = kingpin.Flag("collector.meminfo_numa.vmstat-fields", "Regexp of per-node vmstat fields to return for meminfo_numa collector.").Default("^(numa_|pgpromote|pgdemote|workingset_).*").String()
+)
 
 type meminfoMetric struct {
 	metricName string
@@ -43,7 +51,10 @@ type meminfoMetric struct {
 }
 
 type meminfoNumaCollector struct {
-	metricDescs map[string]*prometheus.Desc
+	metricDescs       map[string]*prometheus.Desc
+	vmStatDescs       map[string]*prometheus.Desc
+	vmStatPattern     *regexp.Regexp
+	distance, nodeCPU typedDesc
 }
 
 func init() {
@@ -53,7 +64,19 @@ func init() {
 // NewMeminfoNumaCollector returns a new Collector exposing memory stats.
 func NewMeminfoNumaCollector() (Collector, error) {
 	return &meminfoNumaCollector{
-		metricDescs: map[string]*prometheus.Desc{},
+		metricDescs:   map[string]*prometheus.Desc{},
+		vmStatDescs:   map[string]*prometheus.Desc{},
+		vmStatPattern: regexp.MustCompile(*numaVMStatFields),
+		distance: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, memInfoNumaSubsystem, "distance"),
+			"Relative distance from a NUMA node to a target node, as reported by the firmware.",
+			[]string{"node", "target_node"}, nil,
+		), prometheus.GaugeValue},
+		nodeCPU: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "numa", "cpus"),
+			"CPUs of a NUMA node, to join CPU metrics with their node.",
+			[]string{"node", "cpu"}, nil,
+		), prometheus.GaugeValue},
 	}, nil
 }
 
@@ -73,9 +96,113 @@ func (c *meminfoNumaCollector) Update(ch chan<- prometheus.Metric) error {
 		}
 		ch <- prometheus.MustNewConstMetric(desc, v.metricType, v.value, v.numaNode)
 	}
+
+	nodes, err := getNumaNodes()
+	if err != nil {
+		return fmt.Errorf("couldn't get NUMA nodes: %s", err)
+	}
+	for _, node := range nodes {
+		if err := c.updateVMStat(ch, node); err != nil {
+			return err
+		}
+
+		distances, err := readNumaDistance(node.path)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return fmt.Errorf("couldn't get distance of NUMA node %s: %s", node.id, err)
+			}
+			log.Debugf("meminfo_numa collector: no distance for node %s: %s", node.id, err)
+		} else if len(distances) != len(nodes) {
+			return fmt.Errorf("got %d distances for NUMA node %s, want %d", len(distances), node.id, len(nodes))
+		}
+		for j, d := range distances {
+			ch <- c.distance.mustNewConstMetric(float64(d), node.id, nodes[j].id)
+		}
+
+		content, err := ioutil.ReadFile(path.Join(node.path, "cpulist"))
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return fmt.Errorf("couldn't get CPUs of NUMA node %s: %s", node.id, err)
+			}
+			log.Debugf("meminfo_numa collector: no cpulist for node %s: %s", node.id, err)
+			continue
+		}
+		cpus, err := parseCPUList(strings.TrimSpace(string(content)))
+		if err != nil {
+			return fmt.Errorf("couldn't parse CPUs of NUMA node %s: %s", node.id, err)
+		}
+		for _, cpu := range cpus {
+			ch <- c.nodeCPU.mustNewConstMetric(1, node.id, strconv.Itoa(cpu))
+		}
+	}
+	return nil
+}
+
+// updateVMStat exposes the per-node vmstat counters. These are the NUMA
+// allocation counters, the page state counters and, on recent kernels, the
+// working set and page promotion and demotion counters. Event counters such as
+// those of automatic NUMA balancing are only accounted system-wide and are not
+// available per node.
+func (c *meminfoNumaCollector) updateVMStat(ch chan<- prometheus.Metric, node numaNode) error {
+	file, err := os.Open(path.Join(node.path, "vmstat"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Debugf("meminfo_numa collector: no vmstat for node %s: %s", node.id, err)
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	vmStat, err := parseMemInfoNumaVMStat(file, node.id)
+	if err != nil {
+		return fmt.Errorf("couldn't parse vmstat of NUMA node %s: %s", node.id, err)
+	}
+	for _, v := range vmStat {
+		if !c.vmStatPattern.MatchString(v.metricName) {
+			continue
+		}
+		desc, ok := c.vmStatDescs[v.metricName]
+		if !ok {
+			desc = prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, memInfoNumaSubsystem, "vmstat_"+v.metricName),
+				fmt.Sprintf("Per-node /proc/vmstat information field %s.", v.metricName),
+				[]string{"node"}, nil)
+			c.vmStatDescs[v.metricName] = desc
+		}
+		ch <- prometheus.MustNewConstMetric(desc, v.metricType, v.value, v.numaNode)
+	}
 	return nil
 }
 
+type numaNode struct {
+	id   string
+	path string
+}
+
+// getNumaNodes returns the NUMA nodes ordered by their ID, which is the order
+// of the distances in the distance files.
+func getNumaNodes() ([]numaNode, error) {
+	paths, err := filepath.Glob(sysFilePath("devices/system/node/node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]numaNode, 0, len(paths))
+	for _, p := range paths {
+		nodeNumber := meminfoNodeRE.FindStringSubmatch(p)
+		if nodeNumber == nil {
+			return nil, fmt.Errorf("device node string didn't match regexp: %s", p)
+		}
+		nodes = append(nodes, numaNode{id: nodeNumber[1], path: p})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		a, _ := strconv.Atoi(nodes[i].id)
+		b, _ := strconv.Atoi(nodes[j].id)
+		return a < b
+	})
+	return nodes, nil
+}
+
 func getMemInfoNuma() ([]meminfoMetric, error) {
 	var (
 		metrics []meminfoMetric
@@ -179,3 +306,71 @@ func parseMemInfoNumaStat(r io.Reader, nodeNumber string) ([]meminfoMetric, erro
 	}
 	return numaStat, scanner.Err()
 }
+
+func parseMemInfoNumaVMStat(r io.Reader, nodeNumber string) ([]meminfoMetric, error) {
+	var (
+		vmStat  []meminfoMetric
+		scanner = bufio.NewScanner(r)
+	)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line scan did not return 2 fields: %s", line)
+		}
+
+		fv, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in vmstat: %s", err)
+		}
+
+		vmStat = append(vmStat, meminfoMetric{parts[0], prometheus.UntypedValue, nodeNumber, fv})
+	}
+	return vmStat, scanner.Err()
+}
+
+func readNumaDistance(nodePath string) ([]uint64, error) {
+	content, err := ioutil.ReadFile(path.Join(nodePath, "distance"))
+	if err != nil {
+		return nil, err
+	}
+	var distances []uint64
+	for _, field := range strings.Fields(string(content)) {
+		d, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid distance: %s", err)
+		}
+		distances = append(distances, d)
+	}
+	return distances, nil
+}
+
+// parseCPUList parses a list of CPUs in the format of the cpulist files, such
+// as "0-3,8,10-11". Memory-only nodes have an empty list.
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	if list == "" {
+		return cpus, nil
+	}
+	for _, r := range strings.Split(list, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
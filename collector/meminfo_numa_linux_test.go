@@ -14,6 +14,7 @@
 package collector
 
 import (
+	"fmt"
 	"os"
 	"testing"
 )
@@ -105,3 +106,45 @@ func TestMemInfoNumaStat(t *testing.T) {
 		t.Errorf("want numa stat other_node %f, got %f", want, got)
 	}
 }
+
+func TestMemInfoNumaVMStat(t *testing.T) {
+	file, err := os.Open("fixtures/sys/devices/system/node/node1/vmstat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	vmStat, err := parseMemInfoNumaVMStat(file, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range vmStat {
+		if v.metricName == "workingset_refault_file" {
+			if want, got := 40112.0, v.value; want != got {
+				t.Errorf("want workingset_refault_file %f, got %f", want, got)
+			}
+			return
+		}
+	}
+	t.Error("workingset_refault_file not found")
+}
+
+func TestParseCPUList(t *testing.T) {
+	for list, want := range map[string][]int{
+		"":            nil,
+		"3":           {3},
+		"0-3,8,10-11": {0, 1, 2, 3, 8, 10, 11},
+	} {
+		got, err := parseCPUList(list)
+		if err != nil {
+			t.Fatalf("%q: %s", list, err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%q: want CPUs %v, got %v", list, want, got)
+		}
+	}
+
+	if _, err := parseCPUList("0-a"); err == nil {
+		t.Error("want error for invalid range")
+	}
+}
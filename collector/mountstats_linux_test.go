@@ -0,0 +1,45 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadNFSMountOptions(t *testing.T) {
+	options, err := readNFSMountOptions("fixtures/proc/self/mountstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want, got := 2, len(options); want != got {
+		t.Fatalf("want %d mounts with options, got %d", want, got)
+	}
+	test := options[nfsMount{device: "192.168.1.1:/srv/test", mountPoint: "/mnt/nfs/test"}]
+	if want := "acdirmax=60,acdirmin=30,acregmax=60,acregmin=3,clientaddr=192.168.1.5,hard,local_lock=none,namlen=255,port=0,proto=tcp,retrans=2,rsize=1048576,rw,sec=sys,timeo=600,vers=4.0,wsize=1048576"; len(test) != 1 || test[0] != want {
+		t.Errorf("want options %q, got %q", want, test)
+	}
+
+	// Both mounts at the same mount point are listed, in order.
+	dupe := options[nfsMount{device: "192.168.1.1:/srv/test", mountPoint: "/mnt/nfs/test-dupe"}]
+	if want, got := 2, len(dupe); want != got {
+		t.Fatalf("want %d option lists for duplicate mount, got %d", want, got)
+	}
+	for i, proto := range []string{"proto=tcp", "proto=udp"} {
+		if !strings.Contains(dupe[i], proto) {
+			t.Errorf("want %s in options %d, got %q", proto, i, dupe[i])
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nonvme
+
+package collector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Constants from linux/nvme_ioctl.h and the NVM Express specification.
+const (
+	// _IOWR('N', 0x41, struct nvme_admin_cmd)
+	nvmeIoctlAdminCmd = 0xc0484e41
+
+	nvmeAdminGetLogPage = 0x02
+	nvmeLogSMART        = 0x02
+	nvmeNSIDAll         = 0xffffffff
+
+	nvmeSMARTLogSize = 512
+)
+
+// nvmePassthruCmd is struct nvme_passthru_cmd.
+type nvmePassthruCmd struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMs   uint32
+	result      uint32
+}
+
+// nvmeIoctlReader reads the SMART log from the controller character device.
+type nvmeIoctlReader struct{}
+
+func (nvmeIoctlReader) SMARTLog(device string) (*nvmeSMARTLog, error) {
+	f, err := os.Open(filepath.Join("/dev", device))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, nvmeSMARTLogSize)
+	cmd := nvmePassthruCmd{
+		opcode:  nvmeAdminGetLogPage,
+		nsid:    nvmeNSIDAll,
+		addr:    uint64(uintptr(unsafe.Pointer(&buf[0]))),
+		dataLen: nvmeSMARTLogSize,
+		// Number of dwords to transfer, zero based, and the log page ID.
+		cdw10: (nvmeSMARTLogSize/4-1)<<16 | nvmeLogSMART,
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(&cmd)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return parseNVMeSMARTLog(buf)
+}
+
+// parseNVMeSMARTLog parses the SMART / Health Information log page. 128 bit
+// counters are truncated to their lower 64 bits.
+func parseNVMeSMARTLog(b []byte) (*nvmeSMARTLog, error) {
+	if len(b) < nvmeSMARTLogSize {
+		return nil, fmt.Errorf("invalid SMART log of length %d", len(b))
+	}
+
+	return &nvmeSMARTLog{
+		CriticalWarning:         b[0],
+		TemperatureKelvin:       binary.LittleEndian.Uint16(b[1:3]),
+		AvailableSpare:          b[3],
+		AvailableSpareThreshold: b[4],
+		PercentageUsed:          b[5],
+		DataUnitsRead:           binary.LittleEndian.Uint64(b[32:40]),
+		DataUnitsWritten:        binary.LittleEndian.Uint64(b[48:56]),
+		PowerCycles:             binary.LittleEndian.Uint64(b[112:120]),
+		PowerOnHours:            binary.LittleEndian.Uint64(b[128:136]),
+		UnsafeShutdowns:         binary.LittleEndian.Uint64(b[144:152]),
+		MediaErrors:             binary.LittleEndian.Uint64(b[160:168]),
+	}, nil
+}
@@ -0,0 +1,242 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nonvme
+
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	nvmeFixtures = kingpin.Flag("collector.nvme.fixtures", "test fixtures to use for nvme collector SMART log metrics").Default("").String()
+)
+
+// nvmeController holds the identity of an NVMe controller found in
+// /sys/class/nvme.
+type nvmeController struct {
+	name            string
+	model           string
+	serial          string
+	firmwareVersion string
+	state           string
+}
+
+// nvmeSMARTLog holds the fields of the SMART / Health Information log page
+// exposed by the collector.
+type nvmeSMARTLog struct {
+	CriticalWarning         uint8
+	TemperatureKelvin       uint16
+	AvailableSpare          uint8
+	AvailableSpareThreshold uint8
+	PercentageUsed          uint8
+	// Data units are thousands of 512 byte blocks.
+	DataUnitsRead    uint64
+	DataUnitsWritten uint64
+	PowerCycles      uint64
+	PowerOnHours     uint64
+	UnsafeShutdowns  uint64
+	MediaErrors      uint64
+}
+
+// nvmeSMARTLogReader is an interface used to swap out the admin passthrough
+// ioctl for end to end tests.
+type nvmeSMARTLogReader interface {
+	SMARTLog(device string) (*nvmeSMARTLog, error)
+}
+
+type nvmeCollector struct {
+	info, temperature, criticalWarning, availableSpare typedDesc
+	availableSpareThreshold, percentageUsed            typedDesc
+	dataRead, dataWritten, powerCycles, powerOnSeconds typedDesc
+	unsafeShutdowns, mediaErrors                       typedDesc
+}
+
+func init() {
+	registerCollector("nvme", defaultDisabled, NewNVMeCollector)
+}
+
+// NewNVMeCollector returns a new Collector exposing NVMe controller
+// information and SMART log statistics.
+func NewNVMeCollector() (Collector, error) {
+	subsystem := "nvme"
+	labels := []string{"device"}
+	return &nvmeCollector{
+		info: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "info"),
+			"Non-numeric data from /sys/class/nvme/<device>, value is always 1.",
+			[]string{"device", "model", "serial", "firmware_revision", "state"}, nil,
+		), prometheus.GaugeValue},
+		temperature: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "temperature_celsius"),
+			"Composite temperature of the controller and its namespaces.",
+			labels, nil,
+		), prometheus.GaugeValue},
+		criticalWarning: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "critical_warning"),
+			"Bit field of the critical warnings for the state of the controller.",
+			labels, nil,
+		), prometheus.GaugeValue},
+		availableSpare: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "available_spare_percent"),
+			"Normalized percentage of the remaining spare capacity available.",
+			labels, nil,
+		), prometheus.GaugeValue},
+		availableSpareThreshold: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "available_spare_threshold_percent"),
+			"Available spare percentage below which a critical warning is raised.",
+			labels, nil,
+		), prometheus.GaugeValue},
+		percentageUsed: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "percentage_used"),
+			"Vendor specific estimate of the percentage of the life of the device used, may exceed 100.",
+			labels, nil,
+		), prometheus.GaugeValue},
+		dataRead: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "data_read_bytes_total"),
+			"Amount of data read by the host.",
+			labels, nil,
+		), prometheus.CounterValue},
+		dataWritten: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "data_written_bytes_total"),
+			"Amount of data written by the host.",
+			labels, nil,
+		), prometheus.CounterValue},
+		powerCycles: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "power_cycles_total"),
+			"Number of power cycles.",
+			labels, nil,
+		), prometheus.CounterValue},
+		powerOnSeconds: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "power_on_seconds_total"),
+			"Power-on time in seconds. The controller counts it in whole hours.",
+			labels, nil,
+		), prometheus.CounterValue},
+		unsafeShutdowns: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "unsafe_shutdowns_total"),
+			"Number of shutdowns without prior notification of the controller.",
+			labels, nil,
+		), prometheus.CounterValue},
+		mediaErrors: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "media_errors_total"),
+			"Number of unrecovered data integrity errors detected by the controller.",
+			labels, nil,
+		), prometheus.CounterValue},
+	}, nil
+}
+
+func (c *nvmeCollector) Update(ch chan<- prometheus.Metric) error {
+	controllers, err := readNVMeControllers(sysFilePath("class/nvme"))
+	if err != nil {
+		return fmt.Errorf("couldn't get NVMe controllers: %s", err)
+	}
+
+	var reader nvmeSMARTLogReader = nvmeIoctlReader{}
+	if *nvmeFixtures != "" {
+		reader = &mockNVMeSMARTLogReader{fixtures: *nvmeFixtures}
+	}
+
+	for _, ctrl := range controllers {
+		ch <- c.info.mustNewConstMetric(1, ctrl.name, ctrl.model, ctrl.serial, ctrl.firmwareVersion, ctrl.state)
+
+		// The admin passthrough ioctl requires CAP_SYS_ADMIN.
+		l, err := reader.SMARTLog(ctrl.name)
+		if err != nil {
+			log.Debugf("nvme collector: couldn't get SMART log of %s: %s", ctrl.name, err)
+			continue
+		}
+		// The temperature is reported in whole kelvins, converted like
+		// nvme-cli and smartctl do.
+		ch <- c.temperature.mustNewConstMetric(float64(int(l.TemperatureKelvin)-273), ctrl.name)
+		ch <- c.criticalWarning.mustNewConstMetric(float64(l.CriticalWarning), ctrl.name)
+		ch <- c.availableSpare.mustNewConstMetric(float64(l.AvailableSpare), ctrl.name)
+		ch <- c.availableSpareThreshold.mustNewConstMetric(float64(l.AvailableSpareThreshold), ctrl.name)
+		ch <- c.percentageUsed.mustNewConstMetric(float64(l.PercentageUsed), ctrl.name)
+		ch <- c.dataRead.mustNewConstMetric(float64(l.DataUnitsRead)*512000, ctrl.name)
+		ch <- c.dataWritten.mustNewConstMetric(float64(l.DataUnitsWritten)*512000, ctrl.name)
+		ch <- c.powerCycles.mustNewConstMetric(float64(l.PowerCycles), ctrl.name)
+		ch <- c.powerOnSeconds.mustNewConstMetric(float64(l.PowerOnHours)*3600, ctrl.name)
+		ch <- c.unsafeShutdowns.mustNewConstMetric(float64(l.UnsafeShutdowns), ctrl.name)
+		ch <- c.mediaErrors.mustNewConstMetric(float64(l.MediaErrors), ctrl.name)
+	}
+	return nil
+}
+
+// readNVMeControllers reads the identity of all NVMe controllers in the given
+// /sys/class/nvme directory.
+func readNVMeControllers(root string) ([]nvmeController, error) {
+	dirs, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var controllers []nvmeController
+	for _, d := range dirs {
+		ctrl := nvmeController{name: d.Name()}
+		for file, dst := range map[string]*string{
+			"model":        &ctrl.model,
+			"serial":       &ctrl.serial,
+			"firmware_rev": &ctrl.firmwareVersion,
+			// Available since Linux 4.16.
+			"state": &ctrl.state,
+		} {
+			b, err := ioutil.ReadFile(filepath.Join(root, d.Name(), file))
+			if err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+			// Identify Controller strings are padded with spaces.
+			*dst = strings.TrimSpace(string(b))
+		}
+		controllers = append(controllers, ctrl)
+	}
+	return controllers, nil
+}
+
+// All code below this point is used to assist with end-to-end tests for
+// the nvme collector, since the SMART log can't be read in CI.
+
+var _ nvmeSMARTLogReader = &mockNVMeSMARTLogReader{}
+
+type mockNVMeSMARTLogReader struct {
+	fixtures string
+}
+
+func (r *mockNVMeSMARTLogReader) SMARTLog(device string) (*nvmeSMARTLog, error) {
+	b, err := ioutil.ReadFile(filepath.Join(r.fixtures, "smart_log.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var logs map[string]*nvmeSMARTLog
+	if err := json.Unmarshal(b, &logs); err != nil {
+		return nil, err
+	}
+	l, ok := logs[device]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return l, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nonvme
+
+package collector
+
+import (
+	"encoding/binary"
+	"testing"
+	"unsafe"
+)
+
+func TestNVMeControllers(t *testing.T) {
+	controllers, err := readNVMeControllers("fixtures/sys/class/nvme")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []nvmeController{
+		{name: "nvme0", model: "Samsung SSD 970 PRO 512GB", serial: "S463NF0M123456V", firmwareVersion: "1B2QEXP7", state: "live"},
+		{name: "nvme1", model: "INTEL SSDPE2KX040T8", serial: "PHLJ812345674P0DGN", firmwareVersion: "VDV10131", state: "resetting"},
+	}
+	if len(controllers) != len(want) {
+		t.Fatalf("want %d controllers, got %d", len(want), len(controllers))
+	}
+	for i := range want {
+		if controllers[i] != want[i] {
+			t.Errorf("want controller %+v, got %+v", want[i], controllers[i])
+		}
+	}
+}
+
+func TestNVMeSMARTLog(t *testing.T) {
+	b := make([]byte, nvmeSMARTLogSize)
+	b[0] = 0x04
+	binary.LittleEndian.PutUint16(b[1:], 310)
+	b[3], b[4], b[5] = 100, 10, 3
+	binary.LittleEndian.PutUint64(b[32:], 25934371)
+	binary.LittleEndian.PutUint64(b[48:], 41628714)
+	binary.LittleEndian.PutUint64(b[144:], 21)
+	binary.LittleEndian.PutUint64(b[160:], 2)
+
+	l, err := parseNVMeSMARTLog(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := nvmeSMARTLog{
+		CriticalWarning:         0x04,
+		TemperatureKelvin:       310,
+		AvailableSpare:          100,
+		AvailableSpareThreshold: 10,
+		PercentageUsed:          3,
+		DataUnitsRead:           25934371,
+		DataUnitsWritten:        41628714,
+		UnsafeShutdowns:         21,
+		MediaErrors:             2,
+	}
+	if *l != want {
+		t.Errorf("want SMART log %+v, got %+v", want, *l)
+	}
+
+	if _, err := parseNVMeSMARTLog(b[:64]); err == nil {
+		t.Error("want error for truncated SMART log")
+	}
+
+	if want, got := uintptr(72), unsafe.Sizeof(nvmePassthruCmd{}); want != got {
+		t.Errorf("want struct nvme_passthru_cmd of size %d, got %d", want, got)
+	}
+}
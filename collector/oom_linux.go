@@ -0,0 +1,295 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nooom
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"github.com/prometheus/procfs"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	oomKmsgPath = kingpin.Flag("collector.oom.kmsg-path", "Path of the kernel log device to watch for OOM kills, empty to disable.").Default("/dev/kmsg").String()
+
+	oomTaskInRE        = regexp.MustCompile(`^Task in (\S+) killed as a result of limit of`)
+	oomKilledProcessRE = regexp.MustCompile(`Killed process (\d+) \((.*?)\)`)
+
+	// Collectors are created for every scrape, so the kernel log is followed
+	// by a single watcher shared between them.
+	oomWatcher     = &oomKmsgWatcher{}
+	oomWatcherOnce sync.Once
+)
+
+type oomVictim struct {
+	pid    string
+	comm   string
+	cgroup string
+	// Seconds since boot at which the process was killed.
+	uptime float64
+}
+
+// oomKmsgWatcher follows the kernel log and keeps track of the OOM kills in it.
+type oomKmsgWatcher struct {
+	mtx     sync.Mutex
+	running bool
+	kills   uint64
+	last    *oomVictim
+
+	// Details of the kill in progress, printed before the "Killed process"
+	// message. Kernels since 4.19 print an "oom-kill:" summary, older ones
+	// only the cgroup whose limit was hit.
+	pendingKill   *oomVictim
+	pendingCgroup string
+}
+
+type oomCollector struct {
+	fs procfs.FS
+
+	cgroupKills, kmsgKills, lastVictim, lastKillTime typedDesc
+}
+
+func init() {
+	registerCollector("oom", defaultDisabled, NewOOMCollector)
+}
+
+// NewOOMCollector returns a new Collector exposing OOM kills per memory
+// cgroup and the last OOM victim found in the kernel log.
+func NewOOMCollector() (Collector, error) {
+	fs, err := procfs.NewFS(*procPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if *oomKmsgPath != "" {
+		oomWatcherOnce.Do(func() {
+			go oomWatcher.watch(*oomKmsgPath)
+		})
+	}
+
+	subsystem := "oom"
+	return &oomCollector{
+		fs: fs,
+		cgroupKills: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "cgroup_kills_total"),
+			"Number of processes killed by the OOM killer in the memory cgroup.",
+			[]string{"cgroup"}, nil,
+		), prometheus.CounterValue},
+		kmsgKills: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "kmsg_kills_total"),
+			"Number of OOM kills found in the kernel log since node_exporter started.",
+			nil, nil,
+		), prometheus.CounterValue},
+		lastVictim: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "last_victim_info"),
+			"Command name and memory cgroup of the last process killed by the OOM killer.",
+			[]string{"comm", "cgroup"}, nil,
+		), prometheus.GaugeValue},
+		lastKillTime: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "last_kill_time_seconds"),
+			"Time of the last OOM kill since unix epoch in seconds.",
+			nil, nil,
+		), prometheus.GaugeValue},
+	}, nil
+}
+
+func (c *oomCollector) Update(ch chan<- prometheus.Metric) error {
+	if err := c.updateCgroups(ch); err != nil {
+		return fmt.Errorf("couldn't get cgroup OOM kills: %s", err)
+	}
+
+	running, kills, last := oomWatcher.state()
+	if !running {
+		return nil
+	}
+	ch <- c.kmsgKills.mustNewConstMetric(float64(kills))
+	if last == nil {
+		return nil
+	}
+	ch <- c.lastVictim.mustNewConstMetric(1, last.comm, last.cgroup)
+
+	stat, err := c.fs.NewStat()
+	if err != nil {
+		return fmt.Errorf("couldn't get boot time: %s", err)
+	}
+	ch <- c.lastKillTime.mustNewConstMetric(float64(stat.BootTime) + last.uptime)
+	return nil
+}
+
+func (c *oomCollector) updateCgroups(ch chan<- prometheus.Metric) error {
+	pattern, err := regexp.Compile(*cgroupsPaths)
+	if err != nil {
+		return fmt.Errorf("invalid --collector.cgroups.paths: %s", err)
+	}
+
+	root := sysFilePath("fs/cgroup")
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		log.Debugf("oom collector: %s does not exist", root)
+		return nil
+	}
+
+	// memory.events exists in v2 and memory.oom_control in v1. Both report
+	// oom_kill since Linux 4.13.
+	file := "memory.events"
+	if !isCgroupV2(root) {
+		root = filepath.Join(root, "memory")
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			log.Debug("oom collector: memory controller is not mounted")
+			return nil
+		}
+		file = "memory.oom_control"
+	}
+
+	return walkCgroups(root, *cgroupsMaxDepth, pattern, func(cgroup, dir string) error {
+		values, err := readCgroupKeyValues(filepath.Join(dir, file))
+		// The root cgroup has no memory.events.
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if v, ok := values["oom_kill"]; ok {
+			ch <- c.cgroupKills.mustNewConstMetric(float64(v), cgroup)
+		}
+		return nil
+	})
+}
+
+func (w *oomKmsgWatcher) state() (running bool, kills uint64, last *oomVictim) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.running, w.kills, w.last
+}
+
+func (w *oomKmsgWatcher) watch(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Errorf("oom collector: couldn't open kernel log, not tracking OOM victims: %s", err)
+		return
+	}
+	defer f.Close()
+
+	// /dev/kmsg starts with the oldest record still in the ring buffer, skip
+	// the kills from before node_exporter started. Regular files, as used by
+	// the end-to-end tests, are read from the start.
+	if fi, err := f.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			log.Errorf("oom collector: couldn't seek to the end of the kernel log, not tracking OOM victims: %s", err)
+			return
+		}
+	}
+
+	if err := w.follow(f); err != nil {
+		log.Errorf("oom collector: stopped following kernel log: %s", err)
+	}
+}
+
+// follow processes the kernel log records read from r until EOF, which only
+// happens for regular files. /dev/kmsg blocks until new records arrive.
+func (w *oomKmsgWatcher) follow(r io.Reader) error {
+	w.mtx.Lock()
+	w.running = true
+	w.mtx.Unlock()
+
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			w.process(strings.TrimSuffix(line, "\n"))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		// Reading /dev/kmsg returns EPIPE if records were overwritten before
+		// they could be read.
+		if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.EPIPE {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// process handles a single /dev/kmsg record of the form
+// "priority,sequence,timestamp,flags;message".
+func (w *oomKmsgWatcher) process(record string) {
+	// Skip continuation lines with key/value metadata.
+	if strings.HasPrefix(record, " ") {
+		return
+	}
+	i := strings.Index(record, ";")
+	if i < 0 {
+		return
+	}
+	prefix, msg := strings.Split(record[:i], ","), record[i+1:]
+	if len(prefix) < 3 {
+		return
+	}
+	usec, err := strconv.ParseUint(prefix[2], 10, 64)
+	if err != nil {
+		return
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if strings.HasPrefix(msg, "oom-kill:") {
+		victim := &oomVictim{}
+		for _, kv := range strings.Split(strings.TrimPrefix(msg, "oom-kill:"), ",") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			switch parts[0] {
+			case "pid":
+				victim.pid = parts[1]
+			case "task":
+				victim.comm = parts[1]
+			case "task_memcg":
+				victim.cgroup = parts[1]
+			}
+		}
+		w.pendingKill = victim
+		return
+	}
+	if m := oomTaskInRE.FindStringSubmatch(msg); m != nil {
+		w.pendingCgroup = m[1]
+		return
+	}
+	if m := oomKilledProcessRE.FindStringSubmatch(msg); m != nil {
+		victim := &oomVictim{pid: m[1], comm: m[2], cgroup: w.pendingCgroup, uptime: float64(usec) / 1e6}
+		if w.pendingKill != nil && w.pendingKill.pid == victim.pid {
+			victim.cgroup = w.pendingKill.cgroup
+		}
+		w.kills++
+		w.last = victim
+		w.pendingKill = nil
+		w.pendingCgroup = ""
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nooom
+
+package collector
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestOOMKmsgWatcher(t *testing.T) {
+	kmsg, err := ioutil.ReadFile("fixtures/oom/kmsg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(kmsg), "\n")
+
+	for _, test := range []struct {
+		name  string
+		lines []string
+		kills uint64
+		last  oomVictim
+	}{
+		{
+			// Kernels before 4.19 only name the cgroup whose limit was hit.
+			name:  "task in",
+			lines: lines[:9],
+			kills: 1,
+			last:  oomVictim{pid: "2314", comm: "java", cgroup: "/system.slice/tomcat.service", uptime: 3602.143655},
+		},
+		{
+			name:  "oom-kill summary",
+			lines: lines,
+			kills: 2,
+			last:  oomVictim{pid: "26232", comm: "nginx", cgroup: "/system.slice/nginx.service", uptime: 7204.381118},
+		},
+	} {
+		w := &oomKmsgWatcher{}
+		if err := w.follow(strings.NewReader(strings.Join(test.lines, ""))); err != nil {
+			t.Fatal(err)
+		}
+
+		running, kills, last := w.state()
+		if !running {
+			t.Errorf("%s: watcher should be running", test.name)
+		}
+		if kills != test.kills {
+			t.Errorf("%s: want %d kills, got %d", test.name, test.kills, kills)
+		}
+		if last == nil || *last != test.last {
+			t.Errorf("%s: want last victim %+v, got %+v", test.name, test.last, last)
+		}
+	}
+}
@@ -16,20 +16,50 @@
 package collector
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/log"
 	"github.com/prometheus/procfs"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	processesPerUser = kingpin.Flag("collector.processes.per-user", "Enable per-UID process and thread counts for the processes collector.").Default("false").Bool()
 )
 
 type processCollector struct {
-	threadAlloc *prometheus.Desc
-	threadLimit *prometheus.Desc
-	procsState  *prometheus.Desc
-	pidUsed     *prometheus.Desc
-	pidMax      *prometheus.Desc
+	fs                procfs.FS
+	perUser           bool
+	threadAlloc       *prometheus.Desc
+	threadLimit       *prometheus.Desc
+	procsState        *prometheus.Desc
+	pidUsed           *prometheus.Desc
+	pidMax            *prometheus.Desc
+	ctxtSwitches      *prometheus.Desc
+	oldestZombieStart *prometheus.Desc
+	userProcs         *prometheus.Desc
+	userThreads       *prometheus.Desc
+}
+
+type processStats struct {
+	pids    int
+	threads int
+	states  map[string]int32
+	// Voluntary and involuntary context switches of the current processes.
+	voluntaryCtxtSwitches    uint64
+	nonvoluntaryCtxtSwitches uint64
+	// Oldest zombie process, if there is any.
+	oldestZombie *procfs.ProcStat
+	// Processes and threads by real UID.
+	userProcs   map[string]int
+	userThreads map[string]int
 }
 
 func init() {
@@ -37,8 +67,14 @@ func init() {
 }
 
 func NewProcessStatCollector() (Collector, error) {
+	fs, err := procfs.NewFS(*procPath)
+	if err != nil {
+		return nil, err
+	}
 	subsystem := "processes"
 	return &processCollector{
+		fs:      fs,
+		perUser: *processesPerUser,
 		threadAlloc: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "threads"),
 			"Allocated threads in system",
@@ -60,47 +96,80 @@ func NewProcessStatCollector() (Collector, error) {
 		pidMax: prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, "max_processes"),
 			"Number of max PIDs limit", nil, nil,
 		),
+		ctxtSwitches: prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, "context_switches"),
+			"Context switches of the current processes. Those of exited processes are not included, so it can decrease.",
+			[]string{"type"}, nil,
+		),
+		oldestZombieStart: prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, "oldest_zombie_start_time_seconds"),
+			"Start time of the oldest zombie process since unix epoch in seconds.", nil, nil,
+		),
+		userProcs: prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, "user_processes"),
+			"Number of processes by real UID.",
+			[]string{"uid"}, nil,
+		),
+		userThreads: prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, "user_threads"),
+			"Number of threads by real UID.",
+			[]string{"uid"}, nil,
+		),
 	}, nil
 }
 func (t *processCollector) Update(ch chan<- prometheus.Metric) error {
-	pids, states, threads, err := getAllocatedThreads()
+	stats, err := t.getAllocatedThreads()
 	if err != nil {
 		return fmt.Errorf("unable to retrieve number of allocated threads: %q", err)
 	}
 
-	ch <- prometheus.MustNewConstMetric(t.threadAlloc, prometheus.GaugeValue, float64(threads))
+	ch <- prometheus.MustNewConstMetric(t.threadAlloc, prometheus.GaugeValue, float64(stats.threads))
 	maxThreads, err := readUintFromFile(procFilePath("sys/kernel/threads-max"))
 	if err != nil {
 		return fmt.Errorf("unable to retrieve limit number of threads: %q", err)
 	}
 	ch <- prometheus.MustNewConstMetric(t.threadLimit, prometheus.GaugeValue, float64(maxThreads))
 
-	for state := range states {
-		ch <- prometheus.MustNewConstMetric(t.procsState, prometheus.GaugeValue, float64(states[state]), state)
+	for state := range stats.states {
+		ch <- prometheus.MustNewConstMetric(t.procsState, prometheus.GaugeValue, float64(stats.states[state]), state)
 	}
 
 	pidM, err := readUintFromFile(procFilePath("sys/kernel/pid_max"))
 	if err != nil {
 		return fmt.Errorf("unable to retrieve limit number of maximum pids alloved: %q", err)
 	}
-	ch <- prometheus.MustNewConstMetric(t.pidUsed, prometheus.GaugeValue, float64(pids))
+	ch <- prometheus.MustNewConstMetric(t.pidUsed, prometheus.GaugeValue, float64(stats.pids))
 	ch <- prometheus.MustNewConstMetric(t.pidMax, prometheus.GaugeValue, float64(pidM))
 
+	ch <- prometheus.MustNewConstMetric(t.ctxtSwitches, prometheus.GaugeValue, float64(stats.voluntaryCtxtSwitches), "voluntary")
+	ch <- prometheus.MustNewConstMetric(t.ctxtSwitches, prometheus.GaugeValue, float64(stats.nonvoluntaryCtxtSwitches), "nonvoluntary")
+
+	if stats.oldestZombie != nil {
+		startTime, err := stats.oldestZombie.StartTime()
+		if err != nil {
+			return fmt.Errorf("unable to retrieve start time of zombie process: %q", err)
+		}
+		ch <- prometheus.MustNewConstMetric(t.oldestZombieStart, prometheus.GaugeValue, startTime)
+	}
+
+	if t.perUser {
+		for uid, v := range stats.userProcs {
+			ch <- prometheus.MustNewConstMetric(t.userProcs, prometheus.GaugeValue, float64(v), uid)
+		}
+		for uid, v := range stats.userThreads {
+			ch <- prometheus.MustNewConstMetric(t.userThreads, prometheus.GaugeValue, float64(v), uid)
+		}
+	}
+
 	return nil
 }
 
-func getAllocatedThreads() (int, map[string]int32, int, error) {
-	fs, err := procfs.NewFS(*procPath)
+func (t *processCollector) getAllocatedThreads() (*processStats, error) {
+	p, err := t.fs.AllProcs()
 	if err != nil {
-		return 0, nil, 0, err
+		return nil, err
 	}
-	p, err := fs.AllProcs()
-	if err != nil {
-		return 0, nil, 0, err
+	stats := &processStats{
+		states:      make(map[string]int32),
+		userProcs:   make(map[string]int),
+		userThreads: make(map[string]int),
 	}
-	pids := 0
-	thread := 0
-	procStates := make(map[string]int32)
 	for _, pid := range p {
 		stat, err := pid.NewStat()
 		// PIDs can vanish between getting the list and getting stats.
@@ -109,11 +178,82 @@ func getAllocatedThreads() (int, map[string]int32, int, error) {
 			continue
 		}
 		if err != nil {
-			return 0, nil, 0, err
+			return nil, err
+		}
+		stats.pids += 1
+		stats.states[stat.State] += 1
+		stats.threads += stat.NumThreads
+
+		if stat.State == "Z" && (stats.oldestZombie == nil || stat.Starttime < stats.oldestZombie.Starttime) {
+			zombie := stat
+			stats.oldestZombie = &zombie
+		}
+
+		status, err := readProcessStatus(procFilePath(filepath.Join(strconv.Itoa(pid.PID), "status")))
+		if os.IsNotExist(err) {
+			log.Debugf("file not found when retrieving status: %q", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		stats.voluntaryCtxtSwitches += status.voluntaryCtxtSwitches
+		stats.nonvoluntaryCtxtSwitches += status.nonvoluntaryCtxtSwitches
+
+		if status.uid == "" {
+			log.Debugf("no uid in status of pid %d", pid.PID)
+			continue
+		}
+		stats.userProcs[status.uid] += 1
+		stats.userThreads[status.uid] += stat.NumThreads
+	}
+	return stats, nil
+}
+
+// processStatus contains the fields of /proc/[pid]/status used by the
+// processes collector.
+type processStatus struct {
+	voluntaryCtxtSwitches    uint64
+	nonvoluntaryCtxtSwitches uint64
+	// Real UID, which RLIMIT_NPROC is enforced against.
+	uid string
+}
+
+func readProcessStatus(path string) (*processStatus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseProcessStatus(f)
+}
+
+func parseProcessStatus(r io.Reader) (*processStatus, error) {
+	var (
+		status  = &processStatus{}
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(kv[1])
+		var err error
+		switch kv[0] {
+		case "Uid":
+			if fields := strings.Fields(value); len(fields) > 0 {
+				status.uid = fields[0]
+			}
+		case "voluntary_ctxt_switches":
+			status.voluntaryCtxtSwitches, err = strconv.ParseUint(value, 10, 64)
+		case "nonvoluntary_ctxt_switches":
+			status.nonvoluntaryCtxtSwitches, err = strconv.ParseUint(value, 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in status: %s", kv[0], err)
 		}
-		pids += 1
-		procStates[stat.State] += 1
-		thread += stat.NumThreads
 	}
-	return pids, procStates, thread, nil
+	return status, scanner.Err()
 }
@@ -16,6 +16,7 @@
 package collector
 
 import (
+	"strings"
 	"testing"
 
 	"gopkg.in/alecthomas/kingpin.v2"
@@ -25,23 +26,52 @@ func TestReadProcessStatus(t *testing.T) {
 	if _, err := kingpin.CommandLine.Parse([]string{"--path.procfs", "fixtures/proc"}); err != nil {
 		t.Fatal(err)
 	}
+	c, err := NewProcessStatCollector()
+	if err != nil {
+		t.Fatal(err)
+	}
 	want := 1
-	pids, states, threads, err := getAllocatedThreads()
+	stats, err := c.(*processCollector).getAllocatedThreads()
 	if err != nil {
 		t.Fatalf("Cannot retrieve data from procfs getAllocatedThreads function: %v ", err)
 	}
-	if threads < want {
-		t.Fatalf("Current threads: %d Shouldn't be less than wanted %d", threads, want)
+	if stats.threads < want {
+		t.Fatalf("Current threads: %d Shouldn't be less than wanted %d", stats.threads, want)
 	}
-	if states == nil {
+	if stats.states == nil {
 
-		t.Fatalf("Process states cannot be nil %v:", states)
+		t.Fatalf("Process states cannot be nil %v:", stats.states)
 	}
 	maxPid, err := readUintFromFile(procFilePath("sys/kernel/pid_max"))
 	if err != nil {
 		t.Fatalf("Unable to retrieve limit number of maximum pids alloved %v\n", err)
 	}
-	if uint64(pids) > maxPid || pids == 0 {
+	if uint64(stats.pids) > maxPid || stats.pids == 0 {
 		t.Fatalf("Total running pids cannot be greater than %d or equals to 0", maxPid)
 	}
+	if want, got := uint64(183486), stats.voluntaryCtxtSwitches; want != got {
+		t.Errorf("want %d voluntary context switches, got %d", want, got)
+	}
+	if want, got := uint64(2240), stats.nonvoluntaryCtxtSwitches; want != got {
+		t.Errorf("want %d nonvoluntary context switches, got %d", want, got)
+	}
+	if stats.oldestZombie == nil || stats.oldestZombie.PID != 26234 {
+		t.Errorf("want oldest zombie with pid 26234, got %v", stats.oldestZombie)
+	}
+	if want, got := 3, stats.userProcs["0"]; want != got {
+		t.Errorf("want %d processes for uid 0, got %d", want, got)
+	}
+	if want, got := 1, stats.userThreads["1000"]; want != got {
+		t.Errorf("want %d threads for uid 1000, got %d", want, got)
+	}
+}
+
+func TestParseProcessStatusWithoutUid(t *testing.T) {
+	status, err := parseProcessStatus(strings.NewReader("Name:\tkthreadd\nvoluntary_ctxt_switches:\t12\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.uid != "" || status.voluntaryCtxtSwitches != 12 {
+		t.Errorf("want no uid and 12 voluntary context switches, got %+v", *status)
+	}
 }
@@ -0,0 +1,189 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !noprocfd
+
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"github.com/prometheus/procfs"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	procFDTopN        = kingpin.Flag("collector.procfd.top-n", "Number of processes with the highest file descriptor usage to report.").Default("10").Int()
+	procFDMaxDuration = kingpin.Flag("collector.procfd.max-duration", "Maximum time to spend walking processes per scrape.").Default("2s").Duration()
+)
+
+type procFDUsage struct {
+	pid   int
+	comm  string
+	open  int
+	limit int64
+	ratio float64
+}
+
+type procFDCollector struct {
+	fs          procfs.FS
+	topN        int
+	maxDuration time.Duration
+
+	openFDs, limitFDs, usageRatio, maxUsageRatio, scanned, truncated typedDesc
+}
+
+func init() {
+	registerCollector("procfd", defaultDisabled, NewProcFDCollector)
+}
+
+// NewProcFDCollector returns a new Collector exposing the processes closest to
+// their open file descriptor limit.
+func NewProcFDCollector() (Collector, error) {
+	if *procFDTopN < 0 {
+		return nil, fmt.Errorf("--collector.procfd.top-n must not be negative")
+	}
+	fs, err := procfs.NewFS(*procPath)
+	if err != nil {
+		return nil, err
+	}
+
+	subsystem := "procfd"
+	labelNames := []string{"pid", "comm"}
+	return &procFDCollector{
+		fs:          fs,
+		topN:        *procFDTopN,
+		maxDuration: *procFDMaxDuration,
+		openFDs: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "open_fds"),
+			"Number of open file descriptors of the processes with the highest file descriptor usage.",
+			labelNames, nil,
+		), prometheus.GaugeValue},
+		limitFDs: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "limit_fds"),
+			"Soft limit of open file descriptors of the processes with the highest file descriptor usage.",
+			labelNames, nil,
+		), prometheus.GaugeValue},
+		usageRatio: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "usage_ratio"),
+			"Ratio of open file descriptors to the soft limit of the processes with the highest file descriptor usage.",
+			labelNames, nil,
+		), prometheus.GaugeValue},
+		maxUsageRatio: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "max_usage_ratio"),
+			"Highest ratio of open file descriptors to the soft limit of any process.",
+			nil, nil,
+		), prometheus.GaugeValue},
+		scanned: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "scanned_processes"),
+			"Number of processes whose file descriptors were inspected.",
+			nil, nil,
+		), prometheus.GaugeValue},
+		truncated: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "scan_truncated"),
+			"Whether the scan stopped early because --collector.procfd.max-duration was exceeded.",
+			nil, nil,
+		), prometheus.GaugeValue},
+	}, nil
+}
+
+func (c *procFDCollector) Update(ch chan<- prometheus.Metric) error {
+	usages, truncated, err := c.getUsages()
+	if err != nil {
+		return fmt.Errorf("couldn't get process file descriptor usage: %s", err)
+	}
+
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].ratio != usages[j].ratio {
+			return usages[i].ratio > usages[j].ratio
+		}
+		return usages[i].pid < usages[j].pid
+	})
+
+	maxRatio := 0.0
+	if len(usages) > 0 {
+		maxRatio = usages[0].ratio
+	}
+	ch <- c.maxUsageRatio.mustNewConstMetric(maxRatio)
+	ch <- c.scanned.mustNewConstMetric(float64(len(usages)))
+	if truncated {
+		ch <- c.truncated.mustNewConstMetric(1)
+	} else {
+		ch <- c.truncated.mustNewConstMetric(0)
+	}
+
+	if len(usages) > c.topN {
+		usages = usages[:c.topN]
+	}
+	for _, u := range usages {
+		pid := strconv.Itoa(u.pid)
+		ch <- c.openFDs.mustNewConstMetric(float64(u.open), pid, u.comm)
+		ch <- c.limitFDs.mustNewConstMetric(float64(u.limit), pid, u.comm)
+		ch <- c.usageRatio.mustNewConstMetric(u.ratio, pid, u.comm)
+	}
+	return nil
+}
+
+// getUsages returns the file descriptor usage of all processes with a finite
+// limit. It stops early and reports truncated if walking the processes takes
+// longer than the configured maximum duration.
+func (c *procFDCollector) getUsages() (usages []procFDUsage, truncated bool, err error) {
+	procs, err := c.fs.AllProcs()
+	if err != nil {
+		return nil, false, err
+	}
+
+	begin := time.Now()
+	for _, p := range procs {
+		if c.maxDuration > 0 && time.Since(begin) > c.maxDuration {
+			log.Debugf("procfd scan stopped after %d of %d processes", len(usages), len(procs))
+			return usages, true, nil
+		}
+
+		// Processes can vanish at any time and the file descriptors of other
+		// users' processes are not readable without root, so skip processes
+		// with unreadable files instead of failing the scrape.
+		limits, err := p.NewLimits()
+		if err != nil {
+			log.Debugf("couldn't read limits of pid %d: %s", p.PID, err)
+			continue
+		}
+		if limits.OpenFiles <= 0 {
+			continue
+		}
+		open, err := p.FileDescriptorsLen()
+		if err != nil {
+			log.Debugf("couldn't read file descriptors of pid %d: %s", p.PID, err)
+			continue
+		}
+		stat, err := p.NewStat()
+		if err != nil {
+			log.Debugf("couldn't read stat of pid %d: %s", p.PID, err)
+			continue
+		}
+
+		usages = append(usages, procFDUsage{
+			pid:   p.PID,
+			comm:  stat.Comm,
+			open:  open,
+			limit: limits.OpenFiles,
+			ratio: float64(open) / float64(limits.OpenFiles),
+		})
+	}
+	return usages, false, nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !noprocfd
+
+package collector
+
+import (
+	"testing"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func TestProcFDUsages(t *testing.T) {
+	if _, err := kingpin.CommandLine.Parse([]string{"--path.procfs", "fixtures/proc"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewProcFDCollector()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usages, truncated, err := c.(*procFDCollector).getUsages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if truncated {
+		t.Error("scan of fixtures should not be truncated")
+	}
+
+	want := map[int]procFDUsage{
+		26231: {pid: 26231, comm: "nginx", open: 6, limit: 8, ratio: 0.75},
+		26232: {pid: 26232, comm: "nginx", open: 9, limit: 16, ratio: 0.5625},
+		26233: {pid: 26233, comm: "sshd", open: 4, limit: 1024, ratio: 0.00390625},
+	}
+	if len(usages) != len(want) {
+		t.Fatalf("want %d processes, got %d: %v", len(want), len(usages), usages)
+	}
+	for _, got := range usages {
+		if want[got.pid] != got {
+			t.Errorf("want %+v, got %+v", want[got.pid], got)
+		}
+	}
+}
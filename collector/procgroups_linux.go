@@ -0,0 +1,269 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !noprocgroups
+
+package collector
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"github.com/prometheus/procfs"
+	"gopkg.in/alecthomas/kingpin.v2"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	procGroupsConfigFile = kingpin.Flag("collector.procgroups.config", "Path to the YAML file mapping processes to groups for the procgroups collector.").Default("").String()
+)
+
+// procGroupsConfig is the format of the file passed in
+// --collector.procgroups.config. A process belongs to the first group whose
+// patterns all match it.
+type procGroupsConfig struct {
+	Groups []procGroupConfig `yaml:"groups"`
+}
+
+type procGroupConfig struct {
+	Name    string `yaml:"name"`
+	Comm    string `yaml:"comm"`
+	Cmdline string `yaml:"cmdline"`
+}
+
+type procGroup struct {
+	name    string
+	comm    *regexp.Regexp
+	cmdline *regexp.Regexp
+}
+
+type procGroupStats struct {
+	processes      int
+	threads        int
+	cpuTime        float64
+	residentMemory int
+	openFDs        int
+	readBytes      uint64
+	writeBytes     uint64
+}
+
+type procGroupsCollector struct {
+	fs          procfs.FS
+	groups      []procGroup
+	needCmdline bool
+
+	processes, threads, cpuTime, residentMemory, openFDs, readBytes, writeBytes typedDesc
+}
+
+func init() {
+	registerCollector("procgroups", defaultDisabled, NewProcGroupsCollector)
+}
+
+// NewProcGroupsCollector returns a new Collector exposing resource usage of
+// processes grouped by the patterns in --collector.procgroups.config.
+func NewProcGroupsCollector() (Collector, error) {
+	if *procGroupsConfigFile == "" {
+		return nil, fmt.Errorf("--collector.procgroups.config must be set for the procgroups collector")
+	}
+	groups, err := loadProcGroups(*procGroupsConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := procfs.NewFS(*procPath)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &procGroupsCollector{fs: fs, groups: groups}
+	for _, g := range groups {
+		if g.cmdline != nil {
+			c.needCmdline = true
+		}
+	}
+
+	subsystem := "procgroups"
+	labelNames := []string{"group"}
+	c.processes = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "processes"),
+		"Number of processes in the group.",
+		labelNames, nil,
+	), prometheus.GaugeValue}
+	c.threads = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "threads"),
+		"Number of threads of the processes in the group.",
+		labelNames, nil,
+	), prometheus.GaugeValue}
+	c.cpuTime = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "cpu_seconds"),
+		"User and system CPU time spent by the current processes in the group in seconds. It decreases when processes exit.",
+		labelNames, nil,
+	), prometheus.GaugeValue}
+	c.residentMemory = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "resident_memory_bytes"),
+		"Resident memory size of the processes in the group in bytes.",
+		labelNames, nil,
+	), prometheus.GaugeValue}
+	c.openFDs = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "open_fds"),
+		"Number of open file descriptors of the processes in the group.",
+		labelNames, nil,
+	), prometheus.GaugeValue}
+	c.readBytes = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "read_bytes"),
+		"Number of bytes the current processes in the group caused to be read from storage. It decreases when processes exit.",
+		labelNames, nil,
+	), prometheus.GaugeValue}
+	c.writeBytes = typedDesc{prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, subsystem, "write_bytes"),
+		"Number of bytes the current processes in the group caused to be written to storage. It decreases when processes exit.",
+		labelNames, nil,
+	), prometheus.GaugeValue}
+
+	return c, nil
+}
+
+func loadProcGroups(filename string) ([]procGroup, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read procgroups config: %s", err)
+	}
+	var config procGroupsConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("couldn't parse procgroups config %s: %s", filename, err)
+	}
+
+	var (
+		groups = make([]procGroup, 0, len(config.Groups))
+		seen   = map[string]bool{}
+	)
+	for _, gc := range config.Groups {
+		if gc.Name == "" {
+			return nil, fmt.Errorf("procgroups config %s: group without name", filename)
+		}
+		if seen[gc.Name] {
+			return nil, fmt.Errorf("procgroups config %s: duplicate group %q", filename, gc.Name)
+		}
+		seen[gc.Name] = true
+		if gc.Comm == "" && gc.Cmdline == "" {
+			return nil, fmt.Errorf("procgroups config %s: group %q needs a comm or cmdline pattern", filename, gc.Name)
+		}
+
+		g := procGroup{name: gc.Name}
+		if gc.Comm != "" {
+			if g.comm, err = regexp.Compile(gc.Comm); err != nil {
+				return nil, fmt.Errorf("procgroups config %s: invalid comm pattern for group %q: %s", filename, gc.Name, err)
+			}
+		}
+		if gc.Cmdline != "" {
+			if g.cmdline, err = regexp.Compile(gc.Cmdline); err != nil {
+				return nil, fmt.Errorf("procgroups config %s: invalid cmdline pattern for group %q: %s", filename, gc.Name, err)
+			}
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// match returns the index of the first group matching the given process, or
+// -1 if no group matches.
+func (c *procGroupsCollector) match(comm, cmdline string) int {
+	for i, g := range c.groups {
+		if g.comm != nil && !g.comm.MatchString(comm) {
+			continue
+		}
+		if g.cmdline != nil && !g.cmdline.MatchString(cmdline) {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+func (c *procGroupsCollector) Update(ch chan<- prometheus.Metric) error {
+	stats, err := c.getGroupStats()
+	if err != nil {
+		return fmt.Errorf("couldn't get process group stats: %s", err)
+	}
+
+	for i, g := range c.groups {
+		s := stats[i]
+		ch <- c.processes.mustNewConstMetric(float64(s.processes), g.name)
+		ch <- c.threads.mustNewConstMetric(float64(s.threads), g.name)
+		ch <- c.cpuTime.mustNewConstMetric(s.cpuTime, g.name)
+		ch <- c.residentMemory.mustNewConstMetric(float64(s.residentMemory), g.name)
+		ch <- c.openFDs.mustNewConstMetric(float64(s.openFDs), g.name)
+		ch <- c.readBytes.mustNewConstMetric(float64(s.readBytes), g.name)
+		ch <- c.writeBytes.mustNewConstMetric(float64(s.writeBytes), g.name)
+	}
+	return nil
+}
+
+func (c *procGroupsCollector) getGroupStats() ([]procGroupStats, error) {
+	procs, err := c.fs.AllProcs()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]procGroupStats, len(c.groups))
+	for _, p := range procs {
+		stat, err := p.NewStat()
+		// PIDs can vanish between getting the list and getting stats.
+		if os.IsNotExist(err) {
+			log.Debugf("file not found when retrieving stats: %q", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var cmdline string
+		if c.needCmdline {
+			args, err := p.CmdLine()
+			if err != nil {
+				log.Debugf("couldn't read cmdline of pid %d: %s", p.PID, err)
+			}
+			cmdline = strings.Join(args, " ")
+		}
+
+		i := c.match(stat.Comm, cmdline)
+		if i < 0 {
+			continue
+		}
+		s := &stats[i]
+		s.processes++
+		s.threads += stat.NumThreads
+		s.cpuTime += stat.CPUTime()
+		s.residentMemory += stat.ResidentMemory()
+
+		// File descriptors and I/O are only readable for processes of the
+		// same user unless running as root, so failing to read them is not
+		// fatal.
+		if fds, err := p.FileDescriptorsLen(); err == nil {
+			s.openFDs += fds
+		} else {
+			log.Debugf("couldn't read file descriptors of pid %d: %s", p.PID, err)
+		}
+		if pio, err := p.NewIO(); err == nil {
+			s.readBytes += pio.ReadBytes
+			s.writeBytes += pio.WriteBytes
+		} else {
+			log.Debugf("couldn't read io of pid %d: %s", p.PID, err)
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !noprocgroups
+
+package collector
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func TestProcGroups(t *testing.T) {
+	if _, err := kingpin.CommandLine.Parse([]string{
+		"--path.procfs", "fixtures/proc",
+		"--collector.procgroups.config", "fixtures/procgroups/config.yml",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewProcGroupsCollector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc := c.(*procGroupsCollector)
+
+	stats, err := pc.getGroupStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pageSize := os.Getpagesize()
+	for i, want := range []procGroupStats{
+		{processes: 2, threads: 2, cpuTime: 24.9, residentMemory: 6084 * pageSize, openFDs: 15, readBytes: 9216, writeBytes: 1050624},
+		{processes: 1, threads: 1, cpuTime: 0.63, residentMemory: 1421 * pageSize, openFDs: 4},
+		{processes: 1, threads: 1, cpuTime: 0.14},
+		{},
+	} {
+		got := stats[i]
+		// Avoid comparing accumulated floats exactly.
+		if d := got.cpuTime - want.cpuTime; d > 1e-9 || d < -1e-9 {
+			t.Errorf("%s: want cpu time %f, got %f", pc.groups[i].name, want.cpuTime, got.cpuTime)
+		}
+		got.cpuTime = want.cpuTime
+		if want != got {
+			t.Errorf("%s: want %+v, got %+v", pc.groups[i].name, want, got)
+		}
+	}
+}
+
+func TestProcGroupsInvalidConfig(t *testing.T) {
+	for _, config := range []string{
+		"groups:\n  - comm: ^nginx$\n",
+		"groups:\n  - name: nginx\n",
+		"groups:\n  - name: nginx\n    comm: (\n",
+		"groups:\n  - name: nginx\n    comm: ^nginx$\n  - name: nginx\n    comm: ^nginx:\n",
+		"groups:\n  - name: nginx\n    exe: /usr/sbin/nginx\n",
+	} {
+		f, err := ioutil.TempFile("", "procgroups")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(config); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		if _, err := loadProcGroups(f.Name()); err == nil {
+			t.Errorf("expected error for config %q", config)
+		}
+	}
+}
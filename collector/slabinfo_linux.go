@@ -0,0 +1,160 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !noslabinfo
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+const (
+	slabinfoSubsystem = "slabinfo"
+)
+
+var (
+	slabInclude = kingpin.Flag("collector.slabinfo.slabs-include", "Regexp of slabs to include. Slabs must both match include and not match exclude to be included.").Default(".*").String()
+	slabExclude = kingpin.Flag("collector.slabinfo.slabs-exclude", "Regexp of slabs to exclude. Slabs must both match include and not match exclude to be included.").Default("").String()
+)
+
+// slab contains the statistics of a slab cache from /proc/slabinfo.
+type slab struct {
+	Name          string
+	ActiveObjects uint64
+	Objects       uint64
+	ObjectSize    uint64
+	PagesPerSlab  uint64
+	ActiveSlabs   uint64
+	Slabs         uint64
+}
+
+type slabinfoCollector struct {
+	includePattern, excludePattern     *regexp.Regexp
+	activeObjects, objects, objectSize typedDesc
+	pagesPerSlab, activeSlabs, slabs   typedDesc
+}
+
+func init() {
+	registerCollector("slabinfo", defaultDisabled, NewSlabinfoCollector)
+}
+
+// NewSlabinfoCollector returns a new Collector exposing slab allocator
+// statistics.
+func NewSlabinfoCollector() (Collector, error) {
+	labels := []string{"slab"}
+	desc := func(name, help string) typedDesc {
+		return typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, slabinfoSubsystem, name),
+			help, labels, nil,
+		), prometheus.GaugeValue}
+	}
+	return &slabinfoCollector{
+		includePattern: regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *slabInclude)),
+		excludePattern: regexp.MustCompile(fmt.Sprintf("^(?:%s)$", *slabExclude)),
+		activeObjects:  desc("active_objects", "Number of objects in use in the slab cache."),
+		objects:        desc("objects", "Number of allocated objects in the slab cache."),
+		objectSize:     desc("object_size_bytes", "Size of the objects of the slab cache."),
+		pagesPerSlab:   desc("pages_per_slab", "Number of pages of each slab of the slab cache."),
+		activeSlabs:    desc("active_slabs", "Number of slabs of the slab cache with objects in use."),
+		slabs:          desc("slabs", "Number of slabs of the slab cache."),
+	}, nil
+}
+
+func (c *slabinfoCollector) Update(ch chan<- prometheus.Metric) error {
+	file, err := os.Open(procFilePath("slabinfo"))
+	if err != nil {
+		// /proc/slabinfo is only readable by root and not there with SLOB.
+		if os.IsNotExist(err) || os.IsPermission(err) {
+			log.Debugf("slabinfo collector: not collecting slab metrics: %s", err)
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	slabs, err := parseSlabinfo(file)
+	if err != nil {
+		return fmt.Errorf("couldn't parse slabinfo: %s", err)
+	}
+
+	for _, s := range slabs {
+		if !c.includePattern.MatchString(s.Name) || c.excludePattern.MatchString(s.Name) {
+			log.Debugf("slabinfo collector: ignoring slab %s", s.Name)
+			continue
+		}
+		ch <- c.activeObjects.mustNewConstMetric(float64(s.ActiveObjects), s.Name)
+		ch <- c.objects.mustNewConstMetric(float64(s.Objects), s.Name)
+		ch <- c.objectSize.mustNewConstMetric(float64(s.ObjectSize), s.Name)
+		ch <- c.pagesPerSlab.mustNewConstMetric(float64(s.PagesPerSlab), s.Name)
+		ch <- c.activeSlabs.mustNewConstMetric(float64(s.ActiveSlabs), s.Name)
+		ch <- c.slabs.mustNewConstMetric(float64(s.Slabs), s.Name)
+	}
+	return nil
+}
+
+// parseSlabinfo parses /proc/slabinfo in the format of version 2.1.
+func parseSlabinfo(r io.Reader) ([]slab, error) {
+	var (
+		slabs   []slab
+		scanner = bufio.NewScanner(r)
+	)
+
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("empty slabinfo: %v", scanner.Err())
+	}
+	if header := scanner.Text(); header != "slabinfo - version: 2.1" {
+		return nil, fmt.Errorf("unsupported slabinfo version: %s", header)
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		// name active_objs num_objs objsize objperslab pagesperslab : tunables
+		// limit batchcount sharedfactor : slabdata active_slabs num_slabs
+		// sharedavail
+		parts := strings.Fields(line)
+		if len(parts) != 16 || parts[6] != ":" || parts[11] != ":" {
+			return nil, fmt.Errorf("invalid line in slabinfo: %s", line)
+		}
+		s := slab{Name: parts[0]}
+		for i, dst := range map[int]*uint64{
+			1:  &s.ActiveObjects,
+			2:  &s.Objects,
+			3:  &s.ObjectSize,
+			5:  &s.PagesPerSlab,
+			13: &s.ActiveSlabs,
+			14: &s.Slabs,
+		} {
+			v, err := strconv.ParseUint(parts[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in slabinfo: %s", err)
+			}
+			*dst = v
+		}
+		slabs = append(slabs, s)
+	}
+	return slabs, scanner.Err()
+}
@@ -0,0 +1,47 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !noslabinfo
+
+package collector
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSlabinfo(t *testing.T) {
+	file, err := os.Open("fixtures/proc/slabinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	slabs, err := parseSlabinfo(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 8, len(slabs); want != got {
+		t.Fatalf("want %d slabs, got %d", want, got)
+	}
+
+	want := slab{Name: "ext4_inode_cache", ActiveObjects: 85710, Objects: 86100, ObjectSize: 1096, PagesPerSlab: 8, ActiveSlabs: 2969, Slabs: 2969}
+	if slabs[0] != want {
+		t.Errorf("want slab %+v, got %+v", want, slabs[0])
+	}
+
+	if _, err := parseSlabinfo(strings.NewReader("slabinfo - version: 1.1\n")); err == nil {
+		t.Error("want error for unsupported version")
+	}
+}
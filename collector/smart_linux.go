@@ -0,0 +1,536 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nosmart
+
+package collector
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	smartDeviceInclude = kingpin.Flag("collector.smart.device-include", "Regexp of devices to read SMART data from.").Default("^sd[a-z]+$").String()
+	smartFixtures      = kingpin.Flag("collector.smart.fixtures", "test fixtures to use for smart collector SCSI commands").Default("").String()
+)
+
+// SCSI and ATA command details from SPC-4, SAT-3 and ACS-3.
+const (
+	scsiInquiry           = 0x12
+	scsiLogSense          = 0x4d
+	scsiATAPassThrough16  = 0x85
+	scsiStatusCheckCond   = 0x02
+	scsiVPDSerialNumber   = 0x80
+	scsiLogTemperature    = 0x0d
+	scsiLogInfoExceptions = 0x2f
+	scsiAllocationLength  = 252
+
+	ataIdentifyDevice     = 0xec
+	ataSMART              = 0xb0
+	ataSMARTReadData      = 0xd0
+	ataSMARTReadThreshold = 0xd1
+	ataSMARTReturnStatus  = 0xda
+	ataProtocolNonData    = 3
+	ataProtocolPIODataIn  = 4
+	ataSectorSize         = 512
+)
+
+// smartAttributeNames are the names smartctl uses for the common vendor
+// independent attributes.
+var smartAttributeNames = map[uint8]string{
+	1:   "raw_read_error_rate",
+	3:   "spin_up_time",
+	4:   "start_stop_count",
+	5:   "reallocated_sector_ct",
+	7:   "seek_error_rate",
+	9:   "power_on_hours",
+	10:  "spin_retry_count",
+	12:  "power_cycle_count",
+	184: "end_to_end_error",
+	187: "reported_uncorrect",
+	188: "command_timeout",
+	190: "airflow_temperature_cel",
+	192: "power_off_retract_count",
+	193: "load_cycle_count",
+	194: "temperature_celsius",
+	196: "reallocated_event_count",
+	197: "current_pending_sector",
+	198: "offline_uncorrectable",
+	199: "udma_crc_error_count",
+	240: "head_flying_hours",
+	241: "total_lbas_written",
+	242: "total_lbas_read",
+}
+
+// scsiResponse is the outcome of a SCSI command.
+type scsiResponse struct {
+	Status uint8
+	Sense  []byte
+	Data   []byte
+}
+
+// scsiCommander is an interface used to swap out the SG_IO ioctl for tests,
+// which replay captured responses.
+type scsiCommander interface {
+	Command(device string, cdb []byte, dataLen int) (*scsiResponse, error)
+}
+
+type smartAttribute struct {
+	id        uint8
+	value     uint8
+	worst     uint8
+	threshold uint8
+	raw       uint64
+}
+
+// smartDevice holds the SMART data read from a disk.
+type smartDevice struct {
+	name            string
+	deviceType      string
+	model           string
+	serial          string
+	firmwareVersion string
+	healthy         bool
+	hasHealth       bool
+	hasTemperature  bool
+	temperature     float64
+	attributes      []smartAttribute
+}
+
+type smartCollector struct {
+	deviceInclude *regexp.Regexp
+	commander     scsiCommander
+
+	info, healthy, temperature                   typedDesc
+	attrValue, attrWorst, attrThreshold, attrRaw typedDesc
+}
+
+func init() {
+	registerCollector("smart", defaultDisabled, NewSMARTCollector)
+}
+
+// NewSMARTCollector returns a new Collector exposing SMART data of SATA and
+// SAS disks.
+func NewSMARTCollector() (Collector, error) {
+	pattern, err := regexp.Compile(*smartDeviceInclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --collector.smart.device-include: %s", err)
+	}
+
+	var commander scsiCommander = sgIOCommander{}
+	if *smartFixtures != "" {
+		commander = &mockSCSICommander{fixtures: *smartFixtures}
+	}
+
+	subsystem := "smart"
+	labels := []string{"device"}
+	attrLabels := []string{"device", "attribute_id", "attribute_name"}
+	return &smartCollector{
+		deviceInclude: pattern,
+		commander:     commander,
+		info: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "device_info"),
+			"Identity of the disk, value is always 1.",
+			[]string{"device", "type", "model", "serial", "firmware_version"}, nil,
+		), prometheus.GaugeValue},
+		healthy: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "healthy"),
+			"Whether the overall health self-assessment of the disk passed.",
+			labels, nil,
+		), prometheus.GaugeValue},
+		temperature: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "temperature_celsius"),
+			"Current temperature of the disk.",
+			labels, nil,
+		), prometheus.GaugeValue},
+		attrValue: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "attribute_value"),
+			"Normalized value of the ATA SMART attribute.",
+			attrLabels, nil,
+		), prometheus.GaugeValue},
+		attrWorst: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "attribute_worst"),
+			"Worst normalized value of the ATA SMART attribute.",
+			attrLabels, nil,
+		), prometheus.GaugeValue},
+		attrThreshold: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "attribute_threshold"),
+			"Normalized value below which the ATA SMART attribute indicates a failure.",
+			attrLabels, nil,
+		), prometheus.GaugeValue},
+		attrRaw: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "attribute_raw_value"),
+			"Vendor specific raw value of the ATA SMART attribute.",
+			attrLabels, nil,
+		), prometheus.GaugeValue},
+	}, nil
+}
+
+func (c *smartCollector) Update(ch chan<- prometheus.Metric) error {
+	blockPath := sysFilePath("block")
+	devices, err := ioutil.ReadDir(blockPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("couldn't list block devices: %s", err)
+	}
+
+	for _, d := range devices {
+		name := d.Name()
+		if !c.deviceInclude.MatchString(name) {
+			continue
+		}
+		vendor, err := ioutil.ReadFile(filepath.Join(blockPath, name, "device", "vendor"))
+		if err != nil {
+			// Not a SCSI disk.
+			continue
+		}
+
+		var dev *smartDevice
+		// SATA disks are attached through the libata SCSI translation.
+		if strings.TrimSpace(string(vendor)) == "ATA" {
+			dev, err = readATASMART(c.commander, name)
+		} else {
+			dev, err = readSCSISMART(c.commander, name, filepath.Join(blockPath, name, "device"))
+		}
+		if err != nil {
+			log.Debugf("smart collector: couldn't read SMART data of %s: %s", name, err)
+			continue
+		}
+
+		ch <- c.info.mustNewConstMetric(1, name, dev.deviceType, dev.model, dev.serial, dev.firmwareVersion)
+		if dev.hasHealth {
+			var healthy float64
+			if dev.healthy {
+				healthy = 1
+			}
+			ch <- c.healthy.mustNewConstMetric(healthy, name)
+		}
+		if dev.hasTemperature {
+			ch <- c.temperature.mustNewConstMetric(dev.temperature, name)
+		}
+		for _, a := range dev.attributes {
+			id := strconv.Itoa(int(a.id))
+			attrName, ok := smartAttributeNames[a.id]
+			if !ok {
+				attrName = "unknown"
+			}
+			ch <- c.attrValue.mustNewConstMetric(float64(a.value), name, id, attrName)
+			ch <- c.attrWorst.mustNewConstMetric(float64(a.worst), name, id, attrName)
+			ch <- c.attrThreshold.mustNewConstMetric(float64(a.threshold), name, id, attrName)
+			ch <- c.attrRaw.mustNewConstMetric(float64(a.raw), name, id, attrName)
+		}
+	}
+	return nil
+}
+
+// ataPassThroughCDB builds an ATA PASS-THROUGH (16) command. Commands
+// reading data transfer a single sector, non-data commands request the ATA
+// registers in the sense data.
+func ataPassThroughCDB(command, features byte) []byte {
+	cdb := make([]byte, 16)
+	cdb[0] = scsiATAPassThrough16
+	if command == ataSMART && features == ataSMARTReturnStatus {
+		cdb[1] = ataProtocolNonData << 1
+		// CK_COND
+		cdb[2] = 0x20
+	} else {
+		cdb[1] = ataProtocolPIODataIn << 1
+		// T_DIR from device, BYT_BLOK, T_LENGTH in the sector count.
+		cdb[2] = 0x0e
+		cdb[6] = 1
+	}
+	cdb[4] = features
+	if command == ataSMART {
+		cdb[10] = 0x4f
+		cdb[12] = 0xc2
+	}
+	cdb[14] = command
+	return cdb
+}
+
+func readATASMART(cmd scsiCommander, device string) (*smartDevice, error) {
+	ataCommand := func(command, features byte) (*scsiResponse, error) {
+		return cmd.Command(device, ataPassThroughCDB(command, features), ataSectorSize)
+	}
+
+	identify, err := ataCommand(ataIdentifyDevice, 0)
+	if err != nil {
+		return nil, fmt.Errorf("IDENTIFY DEVICE failed: %s", err)
+	}
+	dev, err := parseATAIdentify(identify.Data)
+	if err != nil {
+		return nil, err
+	}
+	dev.name = device
+
+	status, err := ataCommand(ataSMART, ataSMARTReturnStatus)
+	if err != nil {
+		return nil, fmt.Errorf("SMART RETURN STATUS failed: %s", err)
+	}
+	if dev.healthy, err = parseATASMARTStatus(status.Sense); err != nil {
+		return nil, err
+	}
+	dev.hasHealth = true
+
+	data, err := ataCommand(ataSMART, ataSMARTReadData)
+	if err != nil {
+		return nil, fmt.Errorf("SMART READ DATA failed: %s", err)
+	}
+	thresholds, err := ataCommand(ataSMART, ataSMARTReadThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("SMART READ THRESHOLDS failed: %s", err)
+	}
+	if dev.attributes, err = parseATASMARTAttributes(data.Data, thresholds.Data); err != nil {
+		return nil, err
+	}
+
+	// The current temperature is kept in the lowest raw byte of
+	// Temperature_Celsius, or of Airflow_Temperature_Cel on some disks.
+	for _, a := range dev.attributes {
+		if a.id == 194 || (a.id == 190 && !dev.hasTemperature) {
+			dev.hasTemperature = true
+			dev.temperature = float64(a.raw & 0xff)
+		}
+	}
+	return dev, nil
+}
+
+// ataString decodes an ATA string, which stores two characters per word
+// with the first one in the high byte.
+func ataString(b []byte) string {
+	s := make([]byte, len(b))
+	for i := 0; i+1 < len(b); i += 2 {
+		s[i], s[i+1] = b[i+1], b[i]
+	}
+	return strings.TrimSpace(string(s))
+}
+
+// parseATAIdentify parses the IDENTIFY DEVICE data.
+func parseATAIdentify(b []byte) (*smartDevice, error) {
+	if len(b) < ataSectorSize {
+		return nil, fmt.Errorf("invalid IDENTIFY DEVICE data of length %d", len(b))
+	}
+	return &smartDevice{
+		deviceType:      "ata",
+		serial:          ataString(b[20:40]),
+		firmwareVersion: ataString(b[46:54]),
+		model:           ataString(b[54:94]),
+	}, nil
+}
+
+// parseATASMARTStatus determines the outcome of SMART RETURN STATUS from the
+// LBA mid and high registers returned in the sense data.
+func parseATASMARTStatus(sense []byte) (bool, error) {
+	var lbaMid, lbaHigh byte
+	switch {
+	case len(sense) >= 8 && sense[0]&0x7f == 0x72:
+		// Descriptor format, look for the ATA Status Return descriptor.
+		found := false
+		for off := 8; off+14 <= len(sense); off += int(sense[off+1]) + 2 {
+			if sense[off] == 0x09 {
+				lbaMid, lbaHigh = sense[off+9], sense[off+11]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, fmt.Errorf("no ATA status return descriptor in sense data")
+		}
+	case len(sense) >= 12 && sense[0]&0x7f == 0x70:
+		lbaMid, lbaHigh = sense[10], sense[11]
+	default:
+		return false, fmt.Errorf("unsupported sense data %x", sense)
+	}
+
+	switch {
+	case lbaMid == 0x4f && lbaHigh == 0xc2:
+		return true, nil
+	case lbaMid == 0xf4 && lbaHigh == 0x2c:
+		return false, nil
+	}
+	return false, fmt.Errorf("unexpected SMART status registers %#x %#x", lbaMid, lbaHigh)
+}
+
+// parseATASMARTAttributes parses the attribute tables of the SMART READ DATA
+// and SMART READ THRESHOLDS sectors.
+func parseATASMARTAttributes(data, thresholds []byte) ([]smartAttribute, error) {
+	if len(data) < ataSectorSize || len(thresholds) < ataSectorSize {
+		return nil, fmt.Errorf("invalid SMART data of length %d and thresholds of length %d", len(data), len(thresholds))
+	}
+
+	limits := map[uint8]uint8{}
+	for off := 2; off < 362; off += 12 {
+		if id := thresholds[off]; id != 0 {
+			limits[id] = thresholds[off+1]
+		}
+	}
+
+	var attrs []smartAttribute
+	for off := 2; off < 362; off += 12 {
+		id := data[off]
+		if id == 0 {
+			continue
+		}
+		raw := make([]byte, 8)
+		copy(raw, data[off+5:off+11])
+		attrs = append(attrs, smartAttribute{
+			id:        id,
+			value:     data[off+3],
+			worst:     data[off+4],
+			threshold: limits[id],
+			raw:       binary.LittleEndian.Uint64(raw),
+		})
+	}
+	return attrs, nil
+}
+
+func readSCSISMART(cmd scsiCommander, device, sysDevicePath string) (*smartDevice, error) {
+	dev := &smartDevice{name: device, deviceType: "scsi"}
+	for file, dst := range map[string]*string{
+		"model": &dev.model,
+		"rev":   &dev.firmwareVersion,
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(sysDevicePath, file))
+		if err != nil {
+			return nil, err
+		}
+		*dst = strings.TrimSpace(string(b))
+	}
+
+	serial, err := cmd.Command(device, []byte{scsiInquiry, 0x01, scsiVPDSerialNumber, 0, scsiAllocationLength, 0}, scsiAllocationLength)
+	if err != nil {
+		return nil, fmt.Errorf("INQUIRY failed: %s", err)
+	}
+	if serial.Status == 0 && len(serial.Data) >= 4 && len(serial.Data) >= 4+int(serial.Data[3]) {
+		dev.serial = strings.TrimSpace(string(serial.Data[4 : 4+int(serial.Data[3])]))
+	}
+
+	ie, err := scsiLogSensePage(cmd, device, scsiLogInfoExceptions)
+	if err != nil {
+		return nil, err
+	}
+	// Not all SAS disks support the informational exceptions page, their
+	// health is unknown.
+	if p, ok := ie[0]; ok && len(p) >= 3 {
+		// A zero additional sense code means no failure is predicted.
+		dev.healthy = p[0] == 0
+		dev.hasHealth = true
+		if p[2] != 0xff {
+			dev.hasTemperature = true
+			dev.temperature = float64(p[2])
+		}
+	}
+
+	temp, err := scsiLogSensePage(cmd, device, scsiLogTemperature)
+	if err != nil {
+		return nil, err
+	}
+	if p, ok := temp[0]; ok && len(p) >= 2 && p[1] != 0xff {
+		dev.hasTemperature = true
+		dev.temperature = float64(p[1])
+	}
+	return dev, nil
+}
+
+// scsiLogSensePage reads the cumulative values of a log page and returns its
+// parameters by parameter code. Unsupported pages have no parameters.
+func scsiLogSensePage(cmd scsiCommander, device string, page byte) (map[uint16][]byte, error) {
+	cdb := []byte{scsiLogSense, 0, 0x40 | page, 0, 0, 0, 0, 0, scsiAllocationLength, 0}
+	resp, err := cmd.Command(device, cdb, scsiAllocationLength)
+	if err != nil {
+		return nil, fmt.Errorf("LOG SENSE of page %#x failed: %s", page, err)
+	}
+	if resp.Status == scsiStatusCheckCond {
+		return nil, nil
+	}
+	return parseSCSILogPage(resp.Data)
+}
+
+func parseSCSILogPage(b []byte) (map[uint16][]byte, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("invalid log page of length %d", len(b))
+	}
+	end := 4 + int(binary.BigEndian.Uint16(b[2:4]))
+	if end > len(b) {
+		end = len(b)
+	}
+
+	params := map[uint16][]byte{}
+	for off := 4; off+4 <= end; {
+		n := int(b[off+3])
+		if off+4+n > end {
+			break
+		}
+		params[binary.BigEndian.Uint16(b[off:off+2])] = b[off+4 : off+4+n]
+		off += 4 + n
+	}
+	return params, nil
+}
+
+// All code below this point is used to assist with end-to-end tests for
+// the smart collector, which replay captured responses to SCSI commands.
+
+var _ scsiCommander = &mockSCSICommander{}
+
+type mockSCSICommander struct {
+	fixtures string
+}
+
+// smartCapture is a captured SCSI command and its response, hex encoded.
+type smartCapture struct {
+	CDB    string `json:"cdb"`
+	Status uint8  `json:"status"`
+	Sense  string `json:"sense"`
+	Data   string `json:"data"`
+}
+
+func (c *mockSCSICommander) Command(device string, cdb []byte, dataLen int) (*scsiResponse, error) {
+	b, err := ioutil.ReadFile(filepath.Join(c.fixtures, device+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var captures []smartCapture
+	if err := json.Unmarshal(b, &captures); err != nil {
+		return nil, err
+	}
+
+	for _, capture := range captures {
+		if capture.CDB != hex.EncodeToString(cdb) {
+			continue
+		}
+		resp := &scsiResponse{Status: capture.Status}
+		if resp.Sense, err = hex.DecodeString(capture.Sense); err != nil {
+			return nil, err
+		}
+		if resp.Data, err = hex.DecodeString(capture.Data); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+	return nil, fmt.Errorf("no captured response to %x", cdb)
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nosmart
+
+package collector
+
+import (
+	"testing"
+)
+
+func TestSMARTATA(t *testing.T) {
+	cmd := &mockSCSICommander{fixtures: "fixtures/smart"}
+
+	for _, test := range []struct {
+		device      string
+		model       string
+		healthy     bool
+		temperature float64
+		attributes  int
+		pending     uint64
+	}{
+		{device: "sda", model: "WDC WD40EFRX-68N32N0", healthy: true, temperature: 36, attributes: 10, pending: 0},
+		// Only has Airflow_Temperature_Cel with the range in the upper bytes.
+		{device: "sdb", model: "ST3000DM001-9YN166", healthy: false, temperature: 33, attributes: 6, pending: 1248},
+	} {
+		dev, err := readATASMART(cmd, test.device)
+		if err != nil {
+			t.Fatalf("%s: %s", test.device, err)
+		}
+		if dev.model != test.model || dev.healthy != test.healthy {
+			t.Errorf("%s: want model %q and healthy %t, got %q and %t", test.device, test.model, test.healthy, dev.model, dev.healthy)
+		}
+		if !dev.hasTemperature || dev.temperature != test.temperature {
+			t.Errorf("%s: want temperature %v, got %v", test.device, test.temperature, dev.temperature)
+		}
+		if len(dev.attributes) != test.attributes {
+			t.Fatalf("%s: want %d attributes, got %d", test.device, test.attributes, len(dev.attributes))
+		}
+		for _, a := range dev.attributes {
+			if a.id == 197 && a.raw != test.pending {
+				t.Errorf("%s: want %d pending sectors, got %d", test.device, test.pending, a.raw)
+			}
+		}
+	}
+}
+
+func TestSMARTSCSI(t *testing.T) {
+	cmd := &mockSCSICommander{fixtures: "fixtures/smart"}
+
+	dev, err := readSCSISMART(cmd, "sdc", "fixtures/sys/block/sdc/device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := smartDevice{
+		name:            "sdc",
+		deviceType:      "scsi",
+		model:           "ST4000NM0023",
+		serial:          "WAF0A1B20000C0011A",
+		firmwareVersion: "0003",
+		healthy:         true,
+		hasTemperature:  true,
+		temperature:     34,
+	}
+	if dev.name != want.name || dev.model != want.model || dev.serial != want.serial || dev.firmwareVersion != want.firmwareVersion ||
+		dev.healthy != want.healthy || dev.temperature != want.temperature || !dev.hasHealth {
+		t.Errorf("want %+v, got %+v", want, *dev)
+	}
+}
+
+func TestSMARTSCSIWithoutInformationalExceptions(t *testing.T) {
+	cmd := &mockSCSICommander{fixtures: "fixtures/smart"}
+
+	// LOG SENSE of the informational exceptions page returns CHECK CONDITION.
+	dev, err := readSCSISMART(cmd, "sdd", "fixtures/sys/block/sdd/device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.hasHealth {
+		t.Error("want unknown health")
+	}
+	if !dev.hasTemperature || dev.temperature != 31 {
+		t.Errorf("want temperature 31, got %v", dev.temperature)
+	}
+}
+
+func TestATASMARTStatusFixedSense(t *testing.T) {
+	sense := make([]byte, 18)
+	sense[0] = 0x70
+	sense[10], sense[11] = 0xf4, 0x2c
+
+	healthy, err := parseATASMARTStatus(sense)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if healthy {
+		t.Error("want threshold exceeded status to be unhealthy")
+	}
+}
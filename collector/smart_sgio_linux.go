@@ -0,0 +1,106 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nosmart
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Constants from scsi/sg.h.
+const (
+	sgIO = 0x2285
+
+	sgInterfaceIDOrig = 'S'
+	sgDxferFromDev    = -3
+
+	sgSenseBufferLen = 32
+	sgTimeoutMs      = 20000
+)
+
+// sgIOHdr is struct sg_io_hdr.
+type sgIOHdr struct {
+	interfaceID    int32
+	dxferDirection int32
+	cmdLen         uint8
+	mxSbLen        uint8
+	iovecCount     uint16
+	dxferLen       uint32
+	dxferp         uintptr
+	cmdp           uintptr
+	sbp            uintptr
+	timeout        uint32
+	flags          uint32
+	packID         int32
+	usrPtr         uintptr
+	status         uint8
+	maskedStatus   uint8
+	msgStatus      uint8
+	sbLenWr        uint8
+	hostStatus     uint16
+	driverStatus   uint16
+	resid          int32
+	duration       uint32
+	info           uint32
+}
+
+// sgIOCommander issues SCSI commands to the block devices in /dev with the
+// SG_IO ioctl, which requires CAP_SYS_RAWIO for ATA PASS-THROUGH.
+type sgIOCommander struct{}
+
+func (sgIOCommander) Command(device string, cdb []byte, dataLen int) (*scsiResponse, error) {
+	f, err := os.OpenFile(filepath.Join("/dev", device), os.O_RDONLY|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, dataLen)
+	sense := make([]byte, sgSenseBufferLen)
+	hdr := sgIOHdr{
+		interfaceID:    sgInterfaceIDOrig,
+		dxferDirection: sgDxferFromDev,
+		cmdLen:         uint8(len(cdb)),
+		mxSbLen:        sgSenseBufferLen,
+		dxferLen:       uint32(dataLen),
+		dxferp:         uintptr(unsafe.Pointer(&data[0])),
+		cmdp:           uintptr(unsafe.Pointer(&cdb[0])),
+		sbp:            uintptr(unsafe.Pointer(&sense[0])),
+		timeout:        sgTimeoutMs,
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), sgIO, uintptr(unsafe.Pointer(&hdr)))
+	if errno != 0 {
+		return nil, errno
+	}
+	if hdr.hostStatus != 0 || hdr.driverStatus&^0x08 != 0 {
+		// DRIVER_SENSE (0x08) only signals that sense data is available.
+		return nil, fmt.Errorf("SG_IO failed with host status %#x, driver status %#x", hdr.hostStatus, hdr.driverStatus)
+	}
+
+	n := dataLen - int(hdr.resid)
+	if n < 0 || n > dataLen {
+		n = dataLen
+	}
+	return &scsiResponse{
+		Status: hdr.status,
+		Sense:  sense[:hdr.sbLenWr],
+		Data:   data[:n],
+	}, nil
+}
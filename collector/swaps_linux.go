@@ -0,0 +1,241 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !noswaps
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/log"
+)
+
+const (
+	swapsSubsystem = "swaps"
+)
+
+// swap contains the statistics of a swap device or file from /proc/swaps.
+type swap struct {
+	Filename string
+	Type     string
+	// Size and Used are in bytes.
+	Size     uint64
+	Used     uint64
+	Priority int64
+}
+
+// zramStats contains the statistics of a zram device from its mm_stat and
+// io_stat files.
+type zramStats struct {
+	Device string
+	// mm_stat fields, in bytes unless noted otherwise.
+	OrigDataSize   uint64
+	ComprDataSize  uint64
+	MemUsedTotal   uint64
+	MemLimit       uint64
+	MemUsedMax     uint64
+	SamePages      uint64
+	PagesCompacted uint64
+	HugePages      uint64
+	// io_stat fields.
+	FailedReads  uint64
+	FailedWrites uint64
+	InvalidIO    uint64
+	NotifyFree   uint64
+}
+
+type swapsCollector struct {
+	size, used, priority                                   typedDesc
+	zramOrigData, zramComprData, zramMemUsed, zramMemLimit typedDesc
+	zramMemUsedMax, zramSamePages, zramPagesCompacted      typedDesc
+	zramHugePages, zramFailedReads, zramFailedWrites       typedDesc
+	zramInvalidIO, zramNotifyFree                          typedDesc
+}
+
+func init() {
+	registerCollector("swaps", defaultDisabled, NewSwapsCollector)
+}
+
+// NewSwapsCollector returns a new Collector exposing swap device and zram
+// statistics.
+func NewSwapsCollector() (Collector, error) {
+	swapLabels := []string{"device", "type"}
+	zramLabels := []string{"device"}
+	desc := func(name, help string, t prometheus.ValueType, labels []string) typedDesc {
+		return typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, swapsSubsystem, name),
+			help, labels, nil,
+		), t}
+	}
+	return &swapsCollector{
+		size:     desc("size_bytes", "Size of the swap device or file.", prometheus.GaugeValue, swapLabels),
+		used:     desc("used_bytes", "Used space of the swap device or file.", prometheus.GaugeValue, swapLabels),
+		priority: desc("priority", "Priority of the swap device or file, higher priorities are used first.", prometheus.GaugeValue, swapLabels),
+
+		zramOrigData:       desc("zram_original_data_bytes", "Uncompressed size of the data stored in the zram device.", prometheus.GaugeValue, zramLabels),
+		zramComprData:      desc("zram_compressed_data_bytes", "Compressed size of the data stored in the zram device.", prometheus.GaugeValue, zramLabels),
+		zramMemUsed:        desc("zram_memory_used_bytes", "Memory allocated by the zram device, including fragmentation and metadata.", prometheus.GaugeValue, zramLabels),
+		zramMemLimit:       desc("zram_memory_limit_bytes", "Maximum memory the zram device can use, 0 if unlimited.", prometheus.GaugeValue, zramLabels),
+		zramMemUsedMax:     desc("zram_memory_used_max_bytes", "Maximum memory allocated by the zram device.", prometheus.GaugeValue, zramLabels),
+		zramSamePages:      desc("zram_same_pages", "Pages of the zram device filled with the same value, which use no memory.", prometheus.GaugeValue, zramLabels),
+		zramPagesCompacted: desc("zram_pages_compacted_total", "Pages freed by compaction of the zram device.", prometheus.CounterValue, zramLabels),
+		zramHugePages:      desc("zram_huge_pages", "Incompressible pages stored in the zram device.", prometheus.GaugeValue, zramLabels),
+		zramFailedReads:    desc("zram_failed_reads_total", "Failed reads of the zram device.", prometheus.CounterValue, zramLabels),
+		zramFailedWrites:   desc("zram_failed_writes_total", "Failed writes of the zram device.", prometheus.CounterValue, zramLabels),
+		zramInvalidIO:      desc("zram_invalid_io_total", "Requests to the zram device that were not aligned to its pages.", prometheus.CounterValue, zramLabels),
+		zramNotifyFree:     desc("zram_notify_free_total", "Pages of the zram device freed because swap slots were released.", prometheus.CounterValue, zramLabels),
+	}, nil
+}
+
+func (c *swapsCollector) Update(ch chan<- prometheus.Metric) error {
+	file, err := os.Open(procFilePath("swaps"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	swaps, err := parseSwaps(file)
+	if err != nil {
+		return fmt.Errorf("couldn't parse swaps: %s", err)
+	}
+	for _, s := range swaps {
+		ch <- c.size.mustNewConstMetric(float64(s.Size), s.Filename, s.Type)
+		ch <- c.used.mustNewConstMetric(float64(s.Used), s.Filename, s.Type)
+		ch <- c.priority.mustNewConstMetric(float64(s.Priority), s.Filename, s.Type)
+	}
+
+	devices, err := filepath.Glob(sysFilePath("block/zram[0-9]*"))
+	if err != nil {
+		return err
+	}
+	for _, dev := range devices {
+		z, err := readZramStats(dev)
+		if err != nil {
+			// mm_stat and io_stat were added in kernel 4.1.
+			if os.IsNotExist(err) {
+				log.Debugf("swaps collector: no zram stats for %s: %s", dev, err)
+				continue
+			}
+			return fmt.Errorf("couldn't get zram stats: %s", err)
+		}
+		ch <- c.zramOrigData.mustNewConstMetric(float64(z.OrigDataSize), z.Device)
+		ch <- c.zramComprData.mustNewConstMetric(float64(z.ComprDataSize), z.Device)
+		ch <- c.zramMemUsed.mustNewConstMetric(float64(z.MemUsedTotal), z.Device)
+		ch <- c.zramMemLimit.mustNewConstMetric(float64(z.MemLimit), z.Device)
+		ch <- c.zramMemUsedMax.mustNewConstMetric(float64(z.MemUsedMax), z.Device)
+		ch <- c.zramSamePages.mustNewConstMetric(float64(z.SamePages), z.Device)
+		ch <- c.zramPagesCompacted.mustNewConstMetric(float64(z.PagesCompacted), z.Device)
+		ch <- c.zramHugePages.mustNewConstMetric(float64(z.HugePages), z.Device)
+		ch <- c.zramFailedReads.mustNewConstMetric(float64(z.FailedReads), z.Device)
+		ch <- c.zramFailedWrites.mustNewConstMetric(float64(z.FailedWrites), z.Device)
+		ch <- c.zramInvalidIO.mustNewConstMetric(float64(z.InvalidIO), z.Device)
+		ch <- c.zramNotifyFree.mustNewConstMetric(float64(z.NotifyFree), z.Device)
+	}
+	return nil
+}
+
+// parseSwaps parses /proc/swaps, which lists the sizes in KiB.
+func parseSwaps(r io.Reader) ([]swap, error) {
+	var (
+		swaps   []swap
+		scanner = bufio.NewScanner(r)
+	)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "Filename") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("invalid line in swaps: %s", line)
+		}
+		// The kernel appends " (deleted)" to swap files unlinked while active,
+		// so only the last four fields are known.
+		n := len(fields) - 4
+		parts := append([]string{strings.Join(fields[:n], " ")}, fields[n:]...)
+		size, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size in swaps: %s", err)
+		}
+		used, err := strconv.ParseUint(parts[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid used size in swaps: %s", err)
+		}
+		priority, err := strconv.ParseInt(parts[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority in swaps: %s", err)
+		}
+		swaps = append(swaps, swap{
+			// Spaces in file names are escaped as in /proc/mounts.
+			Filename: strings.Replace(parts[0], `\040`, " ", -1),
+			Type:     parts[1],
+			Size:     size * 1024,
+			Used:     used * 1024,
+			Priority: priority,
+		})
+	}
+	return swaps, scanner.Err()
+}
+
+func readZramStats(dev string) (*zramStats, error) {
+	z := &zramStats{Device: filepath.Base(dev)}
+
+	// Kernels before 4.19 have no huge_pages, and before 5.19 no
+	// huge_pages_since.
+	if err := readZramStatFile(filepath.Join(dev, "mm_stat"), 7, []*uint64{
+		&z.OrigDataSize, &z.ComprDataSize, &z.MemUsedTotal, &z.MemLimit,
+		&z.MemUsedMax, &z.SamePages, &z.PagesCompacted, &z.HugePages,
+	}); err != nil {
+		return nil, err
+	}
+	if err := readZramStatFile(filepath.Join(dev, "io_stat"), 4, []*uint64{
+		&z.FailedReads, &z.FailedWrites, &z.InvalidIO, &z.NotifyFree,
+	}); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// readZramStatFile reads the space separated values of a zram stat file into
+// dst, which must have at least minFields values.
+func readZramStatFile(file string, minFields int, dst []*uint64) error {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) < minFields {
+		return fmt.Errorf("got %d fields in %s, want at least %d", len(fields), file, minFields)
+	}
+	for i, f := range fields {
+		if i >= len(dst) {
+			break
+		}
+		v, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid value in %s: %s", file, err)
+		}
+		*dst[i] = v
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !noswaps
+
+package collector
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSwaps(t *testing.T) {
+	file, err := os.Open("fixtures/proc/swaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	swaps, err := parseSwaps(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []swap{
+		{Filename: "/dev/dm-1", Type: "partition", Size: 8589930496, Used: 1275068416, Priority: -2},
+		{Filename: "/swapfile", Type: "file", Size: 2147479552, Used: 0, Priority: -3},
+		{Filename: "/dev/zram0", Type: "partition", Size: 4294963200, Used: 629145600, Priority: 100},
+	}
+	if len(swaps) != len(want) {
+		t.Fatalf("want %d swaps, got %d", len(want), len(swaps))
+	}
+	for i := range want {
+		if swaps[i] != want[i] {
+			t.Errorf("want swap %+v, got %+v", want[i], swaps[i])
+		}
+	}
+}
+
+func TestSwapsDeletedFile(t *testing.T) {
+	swaps, err := parseSwaps(strings.NewReader("Filename\t\t\t\tType\t\tSize\t\tUsed\t\tPriority\n" +
+		"/var/swap\\040file (deleted)                  file\t\t1048572\t\t4096\t\t-2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := swap{Filename: "/var/swap file (deleted)", Type: "file", Size: 1073737728, Used: 4194304, Priority: -2}
+	if len(swaps) != 1 || swaps[0] != want {
+		t.Errorf("want swaps [%+v], got %+v", want, swaps)
+	}
+}
+
+func TestZramStats(t *testing.T) {
+	z, err := readZramStats("fixtures/sys/block/zram0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := zramStats{
+		Device:         "zram0",
+		OrigDataSize:   1690644480,
+		ComprDataSize:  412483321,
+		MemUsedTotal:   431534080,
+		MemUsedMax:     441577472,
+		SamePages:      20761,
+		PagesCompacted: 1562,
+		HugePages:      318,
+		FailedWrites:   2,
+		NotifyFree:     28714,
+	}
+	if *z != want {
+		t.Errorf("want zram stats %+v, got %+v", want, *z)
+	}
+}
@@ -32,11 +32,50 @@ const (
 )
 
 var (
-	vmStatFields = kingpin.Flag("collector.vmstat.fields", "Regexp of fields to return for vmstat collector.").Default("^(oom_kill|pgpg|pswp|pg.*fault).*").String()
+	vmStatFields      = kingpin.Flag("collector.vmstat.fields", "Regexp of fields to return for vmstat collector.").Default("^(oom_kill|pgpg|pswp|pg.*fault).*").String()
+	vmStatLegacyNames = kingpin.Flag("collector.vmstat.legacy-names", "Expose all vmstat fields untyped and without the _total suffix of counters, as in earlier versions.").Default("false").Bool()
 )
 
+// vmStatTypes classifies the /proc/vmstat fields by prefix, the first match
+// wins. Fields that match no prefix are exposed untyped.
+var vmStatTypes = []struct {
+	prefix    string
+	valueType prometheus.ValueType
+}{
+	// Most nr_ fields are the current number of pages in a state, except
+	// for these event counts.
+	{"nr_dirtied", prometheus.CounterValue},
+	{"nr_written", prometheus.CounterValue},
+	{"nr_vmscan_", prometheus.CounterValue},
+	{"nr_foll_pin_", prometheus.CounterValue},
+	{"nr_tlb_", prometheus.CounterValue},
+	{"nr_", prometheus.GaugeValue},
+	{"workingset_nodes", prometheus.GaugeValue},
+	{"workingset_", prometheus.CounterValue},
+	{"numa_", prometheus.CounterValue},
+	{"pg", prometheus.CounterValue},
+	{"pswp", prometheus.CounterValue},
+	{"oom_kill", prometheus.CounterValue},
+	{"allocstall", prometheus.CounterValue},
+	{"compact_", prometheus.CounterValue},
+	{"thp_", prometheus.CounterValue},
+	{"htlb_buddy_alloc_", prometheus.CounterValue},
+	{"unevictable_pgs_", prometheus.CounterValue},
+	{"kswapd_", prometheus.CounterValue},
+	{"slabs_scanned", prometheus.CounterValue},
+	{"pageoutrun", prometheus.CounterValue},
+	{"drop_pagecache", prometheus.CounterValue},
+	{"drop_slab", prometheus.CounterValue},
+	{"zone_reclaim_failed", prometheus.CounterValue},
+	{"swap_ra", prometheus.CounterValue},
+	{"balloon_", prometheus.CounterValue},
+	{"zswp", prometheus.CounterValue},
+	{"direct_map_", prometheus.CounterValue},
+}
+
 type vmStatCollector struct {
 	fieldPattern *regexp.Regexp
+	legacyNames  bool
 }
 
 func init() {
@@ -48,6 +87,7 @@ func NewvmStatCollector() (Collector, error) {
 	pattern := regexp.MustCompile(*vmStatFields)
 	return &vmStatCollector{
 		fieldPattern: pattern,
+		legacyNames:  *vmStatLegacyNames,
 	}, nil
 }
 
@@ -69,14 +109,31 @@ func (c *vmStatCollector) Update(ch chan<- prometheus.Metric) error {
 			continue
 		}
 
+		name, valueType := parts[0], prometheus.UntypedValue
+		if !c.legacyNames {
+			valueType = vmStatType(parts[0])
+			if valueType == prometheus.CounterValue {
+				name += "_total"
+			}
+		}
+
 		ch <- prometheus.MustNewConstMetric(
 			prometheus.NewDesc(
-				prometheus.BuildFQName(namespace, vmStatSubsystem, parts[0]),
+				prometheus.BuildFQName(namespace, vmStatSubsystem, name),
 				fmt.Sprintf("/proc/vmstat information field %s.", parts[0]),
 				nil, nil),
-			prometheus.UntypedValue,
+			valueType,
 			value,
 		)
 	}
 	return scanner.Err()
 }
+
+func vmStatType(field string) prometheus.ValueType {
+	for _, t := range vmStatTypes {
+		if strings.HasPrefix(field, t.prefix) {
+			return t.valueType
+		}
+	}
+	return prometheus.UntypedValue
+}
@@ -0,0 +1,41 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !novmstat
+
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestVMStatType(t *testing.T) {
+	for field, want := range map[string]prometheus.ValueType{
+		"nr_free_pages":       prometheus.GaugeValue,
+		"nr_dirty":            prometheus.GaugeValue,
+		"nr_dirtied":          prometheus.CounterValue,
+		"nr_vmscan_write":     prometheus.CounterValue,
+		"workingset_nodes":    prometheus.GaugeValue,
+		"workingset_refault":  prometheus.CounterValue,
+		"pgfault":             prometheus.CounterValue,
+		"oom_kill":            prometheus.CounterValue,
+		"thp_fault_alloc":     prometheus.CounterValue,
+		"some_future_counter": prometheus.UntypedValue,
+	} {
+		if got := vmStatType(field); want != got {
+			t.Errorf("%s: want type %v, got %v", field, want, got)
+		}
+	}
+}
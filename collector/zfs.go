@@ -33,16 +33,34 @@ func init() {
 }
 
 type zfsCollector struct {
-	linuxProcpathBase string
-	linuxZpoolIoPath  string
-	linuxPathMap      map[string]string
+	linuxProcpathBase    string
+	linuxZpoolIoPath     string
+	linuxZpoolObjsetPath string
+	linuxZpoolStatePath  string
+	linuxZpoolTxgsPath   string
+	linuxPathMap         map[string]string
+}
+
+// zpoolStates are the pool states found in /proc/spl/kstat/zfs/<pool>/state.
+var zpoolStates = []string{"online", "degraded", "faulted", "offline", "removed", "unavail", "suspended"}
+
+// zpoolTxgPhases maps the time columns of /proc/spl/kstat/zfs/<pool>/txgs to
+// the phase of the transaction group they cover.
+var zpoolTxgPhases = map[string]string{
+	"otime": "open",
+	"qtime": "quiesce",
+	"wtime": "wait",
+	"stime": "sync",
 }
 
 // NewZFSCollector returns a new Collector exposing ZFS statistics.
 func NewZFSCollector() (Collector, error) {
 	return &zfsCollector{
-		linuxProcpathBase: "spl/kstat/zfs",
-		linuxZpoolIoPath:  "/*/io",
+		linuxProcpathBase:    "spl/kstat/zfs",
+		linuxZpoolIoPath:     "/*/io",
+		linuxZpoolObjsetPath: "/*/objset-*",
+		linuxZpoolStatePath:  "/*/state",
+		linuxZpoolTxgsPath:   "/*/txgs",
 		linuxPathMap: map[string]string{
 			"zfs_abd":         "abdstats",
 			"zfs_arc":         "arcstats",
@@ -110,3 +128,88 @@ func (c *zfsCollector) constPoolMetric(poolName string, sysctl zfsSysctl, value
 		poolName,
 	)
 }
+
+func (c *zfsCollector) constPoolObjsetMetric(poolName string, datasetName string, sysctl zfsSysctl, value uint64) prometheus.Metric {
+	metricName := sysctl.metricName()
+
+	return prometheus.MustNewConstMetric(
+		prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "zfs_zpool_dataset", metricName),
+			string(sysctl),
+			[]string{"zpool", "dataset"},
+			nil,
+		),
+		prometheus.UntypedValue,
+		float64(value),
+		poolName,
+		datasetName,
+	)
+}
+
+func (c *zfsCollector) constPoolStateMetric(poolName string, stateName string, isActive uint64) prometheus.Metric {
+	return prometheus.MustNewConstMetric(
+		prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "zfs_zpool", "state"),
+			"kstat.zfs.misc.state",
+			[]string{"zpool", "state"},
+			nil,
+		),
+		prometheus.GaugeValue,
+		float64(isActive),
+		poolName,
+		stateName,
+	)
+}
+
+func (c *zfsCollector) constPoolTxgMetrics(poolName string, txg map[string]uint64) []prometheus.Metric {
+	metrics := []prometheus.Metric{
+		prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "zfs_zpool_txg", "last_synced"),
+				"Number of the last transaction group synced to disk.",
+				[]string{"zpool"},
+				nil,
+			),
+			prometheus.GaugeValue,
+			float64(txg["txg"]),
+			poolName,
+		),
+		prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "zfs_zpool_txg", "last_synced_dirty_bytes"),
+				"Dirty data in the last transaction group synced to disk.",
+				[]string{"zpool"},
+				nil,
+			),
+			prometheus.GaugeValue,
+			float64(txg["ndirty"]),
+			poolName,
+		),
+		prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "zfs_zpool_txg", "last_synced_written_bytes"),
+				"Bytes written by the last transaction group synced to disk.",
+				[]string{"zpool"},
+				nil,
+			),
+			prometheus.GaugeValue,
+			float64(txg["nwritten"]),
+			poolName,
+		),
+	}
+	for field, phase := range zpoolTxgPhases {
+		metrics = append(metrics, prometheus.MustNewConstMetric(
+			prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "zfs_zpool_txg", "last_synced_phase_seconds"),
+				"Time the last transaction group synced to disk spent in each phase.",
+				[]string{"zpool", "phase"},
+				nil,
+			),
+			prometheus.GaugeValue,
+			float64(txg[field])/1e9,
+			poolName,
+			phase,
+		))
+	}
+	return metrics
+}
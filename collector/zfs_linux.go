@@ -69,10 +69,6 @@ func (c *zfsCollector) updatePoolStats(ch chan<- prometheus.Metric) error {
 		return err
 	}
 
-	if zpoolPaths == nil {
-		return nil
-	}
-
 	for _, zpoolPath := range zpoolPaths {
 		file, err := os.Open(zpoolPath)
 		if err != nil {
@@ -90,6 +86,85 @@ func (c *zfsCollector) updatePoolStats(ch chan<- prometheus.Metric) error {
 		}
 	}
 
+	// The io kstat has been removed in OpenZFS 0.8, the per-dataset objset
+	// kstats replace it.
+	zpoolObjsetPaths, err := filepath.Glob(procFilePath(filepath.Join(c.linuxProcpathBase, c.linuxZpoolObjsetPath)))
+	if err != nil {
+		return err
+	}
+
+	for _, zpoolPath := range zpoolObjsetPaths {
+		file, err := os.Open(zpoolPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// datasets and pools can go away between the glob and the open
+				log.Debugf("Cannot open %q for reading", zpoolPath)
+				continue
+			}
+			return err
+		}
+
+		err = c.parsePoolObjsetFile(file, zpoolPath, func(poolName string, datasetName string, s zfsSysctl, v uint64) {
+			ch <- c.constPoolObjsetMetric(poolName, datasetName, s, v)
+		})
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	zpoolStatePaths, err := filepath.Glob(procFilePath(filepath.Join(c.linuxProcpathBase, c.linuxZpoolStatePath)))
+	if err != nil {
+		return err
+	}
+
+	for _, zpoolPath := range zpoolStatePaths {
+		file, err := os.Open(zpoolPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// datasets and pools can go away between the glob and the open
+				log.Debugf("Cannot open %q for reading", zpoolPath)
+				continue
+			}
+			return err
+		}
+
+		err = c.parsePoolStateFile(file, zpoolPath, func(poolName string, stateName string, isActive uint64) {
+			ch <- c.constPoolStateMetric(poolName, stateName, isActive)
+		})
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	zpoolTxgsPaths, err := filepath.Glob(procFilePath(filepath.Join(c.linuxProcpathBase, c.linuxZpoolTxgsPath)))
+	if err != nil {
+		return err
+	}
+
+	for _, zpoolPath := range zpoolTxgsPaths {
+		file, err := os.Open(zpoolPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// datasets and pools can go away between the glob and the open
+				log.Debugf("Cannot open %q for reading", zpoolPath)
+				continue
+			}
+			return err
+		}
+
+		err = c.parsePoolTxgsFile(file, zpoolPath, func(poolName string, txg map[string]uint64) {
+			for _, m := range c.constPoolTxgMetrics(poolName, txg) {
+				ch <- m
+			}
+		})
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -168,3 +243,120 @@ func (c *zfsCollector) parsePoolProcfsFile(reader io.Reader, zpoolPath string, h
 
 	return scanner.Err()
 }
+
+func (c *zfsCollector) parsePoolObjsetFile(reader io.Reader, zpoolPath string, handler func(string, string, zfsSysctl, uint64)) error {
+	scanner := bufio.NewScanner(reader)
+
+	parseLine := false
+	var zpoolName, datasetName string
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+
+		if !parseLine && len(parts) == 3 && parts[0] == "name" && parts[1] == "type" && parts[2] == "data" {
+			parseLine = true
+			continue
+		}
+
+		if !parseLine || len(parts) < 3 {
+			continue
+		}
+
+		zpoolPathElements := strings.Split(zpoolPath, "/")
+		pathLen := len(zpoolPathElements)
+		if pathLen < 2 {
+			return fmt.Errorf("zpool path did not return at least two elements")
+		}
+
+		zpoolName = zpoolPathElements[pathLen-2]
+		if parts[0] == "dataset_name" {
+			// Dataset names may contain spaces.
+			datasetName = strings.Join(parts[2:], " ")
+			continue
+		}
+
+		if parts[1] == KSTAT_DATA_UINT64 {
+			// The objset-0x* file name differs between datasets, the
+			// metric description must not.
+			key := fmt.Sprintf("kstat.zfs.misc.objset.%s", parts[0])
+			value, err := strconv.ParseUint(parts[2], 10, 64)
+			if err != nil {
+				return fmt.Errorf("could not parse expected integer value for %q", key)
+			}
+			handler(zpoolName, datasetName, zfsSysctl(key), value)
+		}
+	}
+	if !parseLine {
+		return fmt.Errorf("did not parse a single %q metric", zpoolPath)
+	}
+
+	return scanner.Err()
+}
+
+func (c *zfsCollector) parsePoolStateFile(reader io.Reader, zpoolPath string, handler func(string, string, uint64)) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Scan()
+
+	actualStateName := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	zpoolName := filepath.Base(filepath.Dir(zpoolPath))
+	for _, stateName := range zpoolStates {
+		isActive := uint64(0)
+		if actualStateName == stateName {
+			isActive = 1
+		}
+		handler(zpoolName, stateName, isActive)
+	}
+
+	return nil
+}
+
+// parsePoolTxgsFile calls handler with the columns of the most recent
+// committed transaction group in the txgs history of a pool. Nothing is
+// reported if the history is disabled by zfs_txg_history being 0.
+func (c *zfsCollector) parsePoolTxgsFile(reader io.Reader, zpoolPath string, handler func(string, map[string]uint64)) error {
+	scanner := bufio.NewScanner(reader)
+
+	var fields []string
+	var last map[string]uint64
+	for scanner.Scan() {
+		line := strings.Fields(scanner.Text())
+
+		if fields == nil {
+			if len(line) > 0 && line[0] == "txg" {
+				fields = line
+			}
+			continue
+		}
+		if len(line) != len(fields) {
+			continue
+		}
+
+		txg := make(map[string]uint64, len(fields))
+		committed := false
+		for i, field := range fields {
+			if field == "state" {
+				committed = line[i] == "C"
+				continue
+			}
+			value, err := strconv.ParseUint(line[i], 10, 64)
+			if err != nil {
+				return fmt.Errorf("could not parse expected integer value for %q in %q: %v", field, zpoolPath, err)
+			}
+			txg[field] = value
+		}
+		if committed {
+			last = txg
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if last != nil {
+		handler(filepath.Base(filepath.Dir(zpoolPath)), last)
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ package collector
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -454,3 +455,102 @@ func TestVdevMirrorstatsParsing(t *testing.T) {
 		t.Fatal("VdevMirrorStats parsing handler was not called for some expected sysctls")
 	}
 }
+
+func TestZpoolObjsetParsing(t *testing.T) {
+	zpoolPaths, err := filepath.Glob("fixtures/proc/spl/kstat/zfs/*/objset-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := zfsCollector{}
+
+	handlerCalled := false
+	for _, zpoolPath := range zpoolPaths {
+		file, err := os.Open(zpoolPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.parsePoolObjsetFile(file, zpoolPath, func(poolName string, datasetName string, s zfsSysctl, v uint64) {
+			if s != zfsSysctl("kstat.zfs.misc.objset.nwritten") {
+				return
+			}
+
+			handlerCalled = true
+
+			want := map[string]uint64{"pool1": 0, "pool1/dataset1": 12302, "poolz1/home dir": 13271552}
+			if expected, ok := want[datasetName]; !ok || v != expected {
+				t.Fatalf("Incorrect value parsed from procfs data for dataset %q: %v", datasetName, v)
+			}
+			if poolName != strings.SplitN(datasetName, "/", 2)[0] {
+				t.Fatalf("Incorrect pool %q for dataset %q", poolName, datasetName)
+			}
+		})
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !handlerCalled {
+		t.Fatal("Zpool objset parsing handler was not called for some expected sysctls")
+	}
+}
+
+func TestZpoolStateParsing(t *testing.T) {
+	zpoolPaths, err := filepath.Glob("fixtures/proc/spl/kstat/zfs/*/state")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := zfsCollector{}
+
+	active := map[string]string{}
+	for _, zpoolPath := range zpoolPaths {
+		file, err := os.Open(zpoolPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.parsePoolStateFile(file, zpoolPath, func(poolName string, stateName string, isActive uint64) {
+			if isActive == 1 {
+				active[poolName] = stateName
+			}
+		})
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if active["pool1"] != "online" || active["poolz1"] != "degraded" {
+		t.Fatalf("Incorrect pool states parsed from procfs data: %v", active)
+	}
+}
+
+func TestZpoolTxgsParsing(t *testing.T) {
+	file, err := os.Open("fixtures/proc/spl/kstat/zfs/pool1/txgs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	c := zfsCollector{}
+
+	handlerCalled := false
+	err = c.parsePoolTxgsFile(file, "fixtures/proc/spl/kstat/zfs/pool1/txgs", func(poolName string, txg map[string]uint64) {
+		handlerCalled = true
+
+		if poolName != "pool1" {
+			t.Fatalf("Incorrect pool name %q", poolName)
+		}
+		// 4084 is still syncing and 4085 open.
+		if txg["txg"] != 4083 || txg["nwritten"] != 1187840 || txg["stime"] != 18033102 {
+			t.Fatalf("Incorrect transaction group parsed from procfs data: %v", txg)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !handlerCalled {
+		t.Fatal("Zpool txgs parsing handler was not called")
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nozoneinfo
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	zoneinfoSubsystem = "zoneinfo"
+)
+
+var zoneinfoZoneRE = regexp.MustCompile(`^Node (\d+), zone\s+(\S+)$`)
+
+// zoneinfo contains the statistics of a memory zone from /proc/zoneinfo. The
+// values are in pages.
+type zoneinfo struct {
+	Node string
+	Zone string
+	// Watermarks below which kswapd and direct reclaim kick in.
+	Min, Low, High uint64
+	// Pages spanned by the zone, present in it and managed by the buddy
+	// allocator.
+	Spanned, Present, Managed uint64
+	// Pages kept free against allocations that could use the zone of the
+	// same index of the node.
+	Protection []uint64
+	// Per-zone vmstat counters such as nr_free_pages.
+	VMStat map[string]uint64
+}
+
+type zoneinfoCollector struct {
+	min, low, high            typedDesc
+	spanned, present, managed typedDesc
+	protection                typedDesc
+	vmStatDescs               map[string]*prometheus.Desc
+}
+
+func init() {
+	registerCollector("zoneinfo", defaultDisabled, NewZoneinfoCollector)
+}
+
+// NewZoneinfoCollector returns a new Collector exposing per-zone memory
+// statistics from /proc/zoneinfo.
+func NewZoneinfoCollector() (Collector, error) {
+	labels := []string{"node", "zone"}
+	desc := func(name, help string) typedDesc {
+		return typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, zoneinfoSubsystem, name),
+			help, labels, nil,
+		), prometheus.GaugeValue}
+	}
+	return &zoneinfoCollector{
+		min:     desc("min_pages", "Min watermark of the zone, below which allocations enter direct reclaim."),
+		low:     desc("low_pages", "Low watermark of the zone, below which kswapd is woken up."),
+		high:    desc("high_pages", "High watermark of the zone, at which kswapd goes back to sleep."),
+		spanned: desc("spanned_pages", "Pages spanned by the zone, including holes."),
+		present: desc("present_pages", "Physical pages present in the zone."),
+		managed: desc("managed_pages", "Present pages managed by the buddy allocator."),
+		protection: typedDesc{prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, zoneinfoSubsystem, "protection_pages"),
+			"Pages of the zone kept free against allocations that can use the target zone.",
+			[]string{"node", "zone", "target_zone"}, nil,
+		), prometheus.GaugeValue},
+		vmStatDescs: map[string]*prometheus.Desc{},
+	}, nil
+}
+
+func (c *zoneinfoCollector) Update(ch chan<- prometheus.Metric) error {
+	file, err := os.Open(procFilePath("zoneinfo"))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	zones, err := parseZoneinfo(file)
+	if err != nil {
+		return fmt.Errorf("couldn't parse zoneinfo: %s", err)
+	}
+
+	// The protection of a zone has an entry for every zone of the node, in
+	// the order they are listed in.
+	nodeZones := map[string][]string{}
+	for _, z := range zones {
+		nodeZones[z.Node] = append(nodeZones[z.Node], z.Zone)
+	}
+
+	for _, z := range zones {
+		ch <- c.min.mustNewConstMetric(float64(z.Min), z.Node, z.Zone)
+		ch <- c.low.mustNewConstMetric(float64(z.Low), z.Node, z.Zone)
+		ch <- c.high.mustNewConstMetric(float64(z.High), z.Node, z.Zone)
+		ch <- c.spanned.mustNewConstMetric(float64(z.Spanned), z.Node, z.Zone)
+		ch <- c.present.mustNewConstMetric(float64(z.Present), z.Node, z.Zone)
+		ch <- c.managed.mustNewConstMetric(float64(z.Managed), z.Node, z.Zone)
+
+		for i, v := range z.Protection {
+			target := strconv.Itoa(i)
+			if len(z.Protection) == len(nodeZones[z.Node]) {
+				target = nodeZones[z.Node][i]
+			}
+			ch <- c.protection.mustNewConstMetric(float64(v), z.Node, z.Zone, target)
+		}
+
+		for name, v := range z.VMStat {
+			desc, ok := c.vmStatDescs[name]
+			if !ok {
+				desc = prometheus.NewDesc(
+					prometheus.BuildFQName(namespace, zoneinfoSubsystem, name),
+					fmt.Sprintf("Per-zone vmstat information field %s.", name),
+					[]string{"node", "zone"}, nil)
+				c.vmStatDescs[name] = desc
+			}
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.UntypedValue, float64(v), z.Node, z.Zone)
+		}
+	}
+	return nil
+}
+
+// parseZoneinfo parses /proc/zoneinfo. The per-node stats listed with the
+// first zone of a node and the per-CPU pagesets are skipped.
+func parseZoneinfo(r io.Reader) ([]zoneinfo, error) {
+	var (
+		zones   []zoneinfo
+		zone    *zoneinfo
+		section string
+		scanner = bufio.NewScanner(r)
+	)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if m := zoneinfoZoneRE.FindStringSubmatch(line); m != nil {
+			zones = append(zones, zoneinfo{Node: m[1], Zone: m[2], VMStat: map[string]uint64{}})
+			zone = &zones[len(zones)-1]
+			section = "zone"
+			continue
+		}
+		if zone == nil {
+			return nil, fmt.Errorf("unexpected line before first zone: %s", line)
+		}
+
+		switch {
+		case line == "per-node stats":
+			section = "node"
+			continue
+		case line == "pagesets":
+			section = "pagesets"
+			continue
+		case strings.HasPrefix(line, "pages free"):
+			// Unpopulated zones don't list their vmstat counters.
+			parts := strings.Fields(line)
+			v, err := strconv.ParseUint(parts[len(parts)-1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid free pages in zoneinfo: %s", err)
+			}
+			zone.VMStat["nr_free_pages"] = v
+			section = "zone"
+			continue
+		case section != "zone":
+			continue
+		case strings.HasPrefix(line, "protection:"):
+			values := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "protection:")), "()")
+			for _, f := range strings.Split(values, ",") {
+				v, err := strconv.ParseUint(strings.TrimSpace(f), 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid protection in line %q: %s", line, err)
+				}
+				zone.Protection = append(zone.Protection, v)
+			}
+			continue
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line scan did not return 2 fields: %s", line)
+		}
+		v, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in zoneinfo: %s", err)
+		}
+		switch parts[0] {
+		case "min":
+			zone.Min = v
+		case "low":
+			zone.Low = v
+		case "high":
+			zone.High = v
+		case "spanned":
+			zone.Spanned = v
+		case "present":
+			zone.Present = v
+		case "managed":
+			zone.Managed = v
+		case "boost", "cma", "scanned":
+			// Not exposed.
+		default:
+			zone.VMStat[parts[0]] = v
+		}
+	}
+	return zones, scanner.Err()
+}
@@ -0,0 +1,66 @@
+// Copyright 2018 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build !nozoneinfo
+
+package collector
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestZoneinfo(t *testing.T) {
+	file, err := os.Open("fixtures/proc/zoneinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	zones, err := parseZoneinfo(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 4, len(zones); want != got {
+		t.Fatalf("want %d zones, got %d", want, got)
+	}
+
+	dma := zones[0]
+	if dma.Node != "0" || dma.Zone != "DMA" || dma.Min != 33 || dma.Low != 41 || dma.High != 49 ||
+		dma.Spanned != 4095 || dma.Present != 3975 || dma.Managed != 3956 {
+		t.Errorf("unexpected zone %+v", dma)
+	}
+	if want, got := "[0 2877 7826 7826]", fmt.Sprint(dma.Protection); want != got {
+		t.Errorf("want protection %s, got %s", want, got)
+	}
+	// Per-node stats must not be taken for the zone's.
+	if _, ok := dma.VMStat["nr_inactive_anon"]; ok {
+		t.Error("want per-node stats to be skipped")
+	}
+	if want, got := uint64(3952), dma.VMStat["nr_free_pages"]; want != got {
+		t.Errorf("want %d free pages, got %d", want, got)
+	}
+
+	normal := zones[2]
+	if want, got := uint64(26812), normal.VMStat["numa_interleave"]; want != got {
+		t.Errorf("want %d interleave hits, got %d", want, got)
+	}
+	if _, ok := normal.VMStat["count:"]; ok {
+		t.Error("want pagesets to be skipped")
+	}
+
+	if movable := zones[3]; movable.Zone != "Movable" || movable.Managed != 0 || len(movable.VMStat) != 1 {
+		t.Errorf("unexpected empty zone %+v", movable)
+	}
+}
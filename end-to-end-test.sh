@@ -5,7 +5,10 @@ set -euf -o pipefail
 enabled_collectors=$(cat << COLLECTORS
   arp
   bcache
+  btrfs
+  cgroups
   buddyinfo
+  cifs
   conntrack
   cpu
   diskstats
@@ -13,6 +16,7 @@ enabled_collectors=$(cat << COLLECTORS
   edac
   entropy
   filefd
+  hugepages
   hwmon
   infiniband
   interrupts
@@ -27,16 +31,24 @@ enabled_collectors=$(cat << COLLECTORS
   netstat
   nfs
   nfsd
+  nvme
+  oom
   qdisc
+  slabinfo
+  smart
   sockstat
   stat
+  swaps
   textfile
   bonding
   vmstat
   wifi
   xfs
   zfs
+  zoneinfo
   processes
+  procgroups
+  procfd
 COLLECTORS
 )
 disabled_collectors=$(cat << COLLECTORS
@@ -97,7 +109,16 @@ fi
   $(for c in ${disabled_collectors}; do echo --no-collector.${c}  ; done) \
   --collector.textfile.directory="collector/fixtures/textfile/two_metric_files/" \
   --collector.wifi.fixtures="collector/fixtures/wifi" \
+  --collector.ipvs.fixtures="collector/fixtures/ipvs" \
+  --collector.nvme.fixtures="collector/fixtures/nvme" \
+  --collector.smart.fixtures="collector/fixtures/smart" \
   --collector.qdisc.fixtures="collector/fixtures/qdisc/" \
+  --collector.procgroups.config="collector/fixtures/procgroups/config.yml" \
+  --collector.ksmd.processes \
+  --collector.self-cgroup \
+  --collector.processes.per-user \
+  --collector.procfd.top-n=2 \
+  --collector.oom.kmsg-path="collector/fixtures/oom/kmsg" \
   --collector.netclass.ignored-devices="(bond0|dmz|int)" \
   --web.listen-address "127.0.0.1:${port}" \
   --log.level="debug" > "${tmpdir}/node_exporter.log" 2>&1 &